@@ -0,0 +1,52 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestAlias(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".alias", Endpoint: s.URL})
+	a.Track("before", nil)
+
+	if err := a.Alias("user-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	anon := a.anonymousID
+	a.Close()
+
+	b := New(&Config{WriteKey: "k", Dir: ".alias", Endpoint: s.URL})
+
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := s.Messages()
+
+	if len(m) != 2 || m[1]["type"] != "alias" {
+		t.Fatalf("expected an alias call, got %v", m)
+	}
+
+	if m[1]["previousId"] != anon || m[1]["userId"] != "user-1" {
+		t.Fatalf("expected the anonymous id aliased, got %v", m[1])
+	}
+}
+
+func TestAlias_empty(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".alias"})
+
+	if err := a.Alias(""); err == nil {
+		t.Fatal("expected an error for an empty id")
+	}
+
+	if n, _ := a.Size(); n != 0 {
+		t.Fatalf("expected nothing buffered, got %d", n)
+	}
+}
@@ -10,36 +10,322 @@
 // invoke Disable() to flag for future invocations. Once disabled tracking is
 // automatically a no-op.
 //
-// Functions of this package are not thread-safe.
+// An Analytics is safe for concurrent use by multiple goroutines.
 package analytics
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"io/ioutil"
 	stdlog "log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/apex/log"
+	"github.com/gofrs/flock"
 	"github.com/hashicorp/go-uuid"
-	"github.com/mitchellh/go-homedir"
 	"github.com/pkg/errors"
 	segment "github.com/segmentio/analytics-go"
 )
 
 // Event used for storage on disk.
 type Event struct {
+	Type       string                 `json:"type,omitempty"`
 	Event      string                 `json:"event"`
 	Properties map[string]interface{} `json:"properties"`
+	Timestamp  time.Time              `json:"timestamp"`
+	BufferedAt time.Time              `json:"buffered_at"`
+	PreviousID string                 `json:"previous_id,omitempty"`
+	UserID     string                 `json:"user_id,omitempty"`
+	MessageID  string                 `json:"message_id,omitempty"`
+	Anonymous  bool                   `json:"anonymous,omitempty"`
+	Priority   Priority               `json:"priority,omitempty"`
+	Traits     map[string]interface{} `json:"traits,omitempty"`
+	GroupID    string                 `json:"group_id,omitempty"`
+
+	Integrations map[string]interface{} `json:"integrations,omitempty"`
+}
+
+// ErrFlushPaused is returned when flushing while paused by PauseFlush().
+var ErrFlushPaused = errors.New("flush paused")
+
+// ErrFlushThrottled is returned when flushing within MinFlushInterval
+// of the last flush, the events are kept for a later flush.
+var ErrFlushThrottled = errors.New("flush throttled")
+
+// ErrOffline is returned when flushing while OfflineCheck reports
+// being offline, the events are kept for a later flush.
+var ErrOffline = errors.New("offline")
+
+// ErrFlushTimeout is returned by FlushTimeout, or when MaxFlushDuration
+// is exceeded, the events are kept for a later flush.
+var ErrFlushTimeout = errors.New("flush timed out")
+
+// ErrEventDisabled is passed to OnDrop for events listed in DisabledEvents.
+var ErrEventDisabled = errors.New("event disabled")
+
+// ErrNoConsent is passed to OnDrop for events in a category without consent.
+var ErrNoConsent = errors.New("no category consent")
+
+// ErrRateLimited is passed to OnDrop for events exceeding MaxTrackRate.
+var ErrRateLimited = errors.New("rate limited")
+
+// ErrBufferFull is passed to OnDrop for the oldest events
+// discarded to keep the buffer within MaxEvents.
+var ErrBufferFull = errors.New("buffer full")
+
+// ErrDroppedBeforeSend is passed to OnDrop for events
+// which BeforeSend removed from a flush.
+var ErrDroppedBeforeSend = errors.New("dropped before send")
+
+// ErrSampled is passed to OnDrop for events dropped by SampleRate.
+var ErrSampled = errors.New("sampled")
+
+// ErrEmptyEventName is returned when tracking an event without a name.
+var ErrEmptyEventName = errors.New("empty event name")
+
+// ErrRetryAgeExceeded is passed to OnDrop for events which have been
+// buffered for longer than MaxRetryAge.
+var ErrRetryAgeExceeded = errors.New("retry age exceeded")
+
+// ErrEventExpired is passed to OnDrop for events whose
+// Timestamp is older than EventTTL.
+var ErrEventExpired = errors.New("event expired")
+
+// HeaderVersion is the current events file format version.
+const HeaderVersion = 1
+
+// Header is the optional first line of the events file, describing
+// it for tooling which reads the raw file.
+type Header struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+}
+
+// record is a line of the events file, either the header or an event.
+type record struct {
+	Header *Header `json:"header,omitempty"`
+	Event
+}
+
+// Event types, an empty type is a track event.
+const (
+	TypeTrack    = "track"
+	TypeAlias    = "alias"
+	TypeIdentify = "identify"
+	TypeGroup    = "group"
+)
+
+// Durability controls when buffered data is synced to disk, trading
+// performance for crash safety.
+type Durability int
+
+// Durability levels.
+const (
+	DurabilityNone         Durability = iota // DurabilityNone leaves syncing to the OS
+	DurabilityOnFlush                        // DurabilityOnFlush syncs before the buffer is removed
+	DurabilityOnEveryWrite                   // DurabilityOnEveryWrite syncs after each Track
+)
+
+// Lifecycle states.
+const (
+	StateInitialized = "initialized"
+	StateDisabled    = "disabled"
+	StateFlushed     = "flushed"
+	StateClosed      = "closed"
+	StateError       = "error"
+)
+
+// LifecycleEvent is sent to Config.Lifecycle as the tracker changes state.
+type LifecycleEvent struct {
+	State string // State is one of the State* constants
+	Err   error  // Err is the error for StateError
+}
+
+// ClearMode controls how Flush clears the events file.
+type ClearMode int
+
+// Clear modes.
+const (
+	ClearRemove   ClearMode = iota // ClearRemove removes the events file
+	ClearTruncate                  // ClearTruncate truncates the events file, keeping the inode, so it's flushed in place
+)
+
+// Gate is acquired by Flush before uploading and released after, letting
+// programs throttle analytics against foreground work.
+type Gate interface {
+	Acquire(ctx context.Context) error
+	Release()
+}
+
+// Transport delivers flushed events in place of Segment, such as to a
+// file or a test spy. The events are sent with `userID`, which is the
+// id set by SetUserID or the anonymous id, and Close is called after
+// each flush. Send may return a *RejectedError to keep only the rejected
+// events buffered.
+type Transport interface {
+	Send(userID string, events []*Event) error
+	Close() error
 }
 
 // Config for analytics tracker.
 type Config struct {
-	WriteKey string        // WriteKey from Segment
-	Dir      string        // Dir relative to ~ for storing state
-	Log      log.Interface // Log (optional)
+	WriteKey     string        // WriteKey from Segment, falling back to WriteKeyEnv then WriteKeyFile
+	WriteKeyEnv  string        // WriteKeyEnv is the environment variable holding the WriteKey, defaults to SEGMENT_WRITE_KEY
+	WriteKeyFile string        // WriteKeyFile is the path of a file holding the WriteKey (optional)
+	Dir          string        // Dir relative to ~ for storing state, or an absolute path
+	DirPerm      os.FileMode   // DirPerm of the created directories, defaults to 0700, ignored on Windows
+	FilePerm     os.FileMode   // FilePerm of the created files, defaults to 0600, only the read-only bit applies on Windows
+	Namespace    string        // Namespace scopes state to ~/<dir>/<namespace>, letting trackers share Dir (optional)
+	Log          log.Interface // Log (optional)
+	Endpoint     string        // Endpoint of the Segment API, an http(s) URL (optional)
+	Output       io.Writer     // Output receives flushed events as NDJSON instead of Segment (optional)
+
+	OnRequest  func(*http.Request, *http.Response, error) // OnRequest is called for each flush request, during the flush so it must not call the Analytics methods (optional)
+	OnFlush    func(count int, err error)                 // OnFlush is called after each flush with the number of events sent (optional)
+	DirectHTTP bool                                       // DirectHTTP posts batches with net/http instead of the Segment client
+	HTTPClient *http.Client                               // HTTPClient used for flushing, for proxies, TLS settings, or timeouts (optional)
+
+	Integrations map[string]interface{} // Integrations enabled for track events by default, such as {"All": true} (optional)
+
+	Dedup bool // Dedup collapses identical consecutive events into one with a "count" property (optional)
+
+	OfflineCheck func() bool // OfflineCheck returns true when offline, skipping flushes with ErrOffline (optional)
+
+	BatchSize     int           // BatchSize of the Segment client's requests, defaults to the client's
+	FlushInterval time.Duration // FlushInterval of the Segment client's background flushes, defaults to the client's
+
+	FlushOnEvents  []string // FlushOnEvents are event names which trigger an immediate flush (optional)
+	DisabledEvents []string // DisabledEvents are event names which are dropped by Track (optional)
+	AnonymizeKeys  []string // AnonymizeKeys are property names removed by Anonymize() (optional)
+
+	MaxBufferAge time.Duration // MaxBufferAge flushes on Track when the oldest event exceeds it (optional)
+
+	Categories             map[string]string // Categories maps event names to consent categories (optional)
+	RequireCategoryConsent bool              // RequireCategoryConsent drops categorized events without consent
+
+	Durability Durability // Durability level, defaults to DurabilityNone
+
+	MinEventsToFlush int // MinEventsToFlush makes Flush a no-op below this many events (optional)
+
+	OnStorageUnavailable func(error) // OnStorageUnavailable is called when ~/<dir> can't be written (optional)
+	FallbackInMemory     bool        // FallbackInMemory buffers events in memory when ~/<dir> can't be written
+
+	MaxRetryAge time.Duration       // MaxRetryAge drops events buffered longer than this on Flush, and hides them from Events (optional)
+	EventTTL    time.Duration       // EventTTL drops events whose Timestamp is older than this on Flush and Compact, and hides them from Events (optional)
+	OnDrop      func(*Event, error) // OnDrop is called with each dropped event and the reason (optional)
+	BeforeSend  func(*Event) *Event // BeforeSend transforms each event when flushing, returning nil drops it, it must not call the Analytics methods (optional)
+
+	FlushEveryNRuns int // FlushEveryNRuns makes ConditionalFlush flush once per N runs (optional)
+
+	FlushJitter time.Duration // FlushJitter randomly shifts ConditionalFlush's aboveDuration by up to this either way, spreading flushes (optional)
+
+	MinFlushInterval time.Duration // MinFlushInterval since the last flush before Flush delivers again, returning ErrFlushThrottled (optional)
+
+	MachineIDSalt string // MachineIDSalt used by MachineID(), defaults to Dir
+
+	DetectTTY bool // DetectTTY adds an "interactive" property, true when stdout is a terminal
+
+	EventsHeader bool // EventsHeader writes a Header as the first line of the events file
+	Compress     bool // Compress gzips each buffered event, plaintext events remain readable
+
+	Compressor Compressor // Compressor used by Compress instead of gzip (optional)
+
+	EncryptionKey []byte // EncryptionKey is a 32 byte AES-256-GCM key sealing buffered events (optional)
+
+	SummaryEvent string // SummaryEvent is the name of a rollup event sent on Flush (optional)
+	SummaryOnly  bool   // SummaryOnly sends the SummaryEvent instead of the individual events
+
+	Lifecycle chan<- LifecycleEvent // Lifecycle receives state changes, dropped when full (optional)
+
+	PropertyKeyCase KeyCase // PropertyKeyCase applied to property keys on Track, defaults to AsIs
+
+	FlushGate Gate // FlushGate held while uploading (optional)
+
+	TrackFirstRun bool // TrackFirstRun tracks a "first_run" event on the first invocation
+
+	OnFirstRun func() // OnFirstRun is called by New on the first invocation, such as to show an opt-out notice (optional)
+
+	InstallSource string // InstallSource is added to events as "install_source" (optional)
+
+	MaxTrackRate     float64 // MaxTrackRate in events per second, excess events are dropped (optional)
+	RateLimit        float64 // RateLimit is an alias of MaxTrackRate, which takes precedence when both are set (optional)
+	BlockOnRateLimit bool    // BlockOnRateLimit makes Track wait for MaxTrackRate instead of dropping, sleeping on the Clock when it is a Sleeper
+
+	SocketPath string // SocketPath of a local collector receiving events as NDJSON, falling back to disk (optional)
+
+	FlushClearMode ClearMode // FlushClearMode defaults to ClearRemove
+
+	TrackPreviousEvent bool // TrackPreviousEvent adds a "previous_event" property naming the last event
+
+	SequenceProperty string // SequenceProperty such as "_seq" holding a number increasing with each event across runs (optional)
+
+	PriorityFlushSize int // PriorityFlushSize of the high priority buffer triggering a flush, defaults to 1
+
+	FlushRetries int           // FlushRetries is the number of times a failed delivery is retried (optional)
+	FlushBackoff time.Duration // FlushBackoff before the first retry, doubling for each retry, defaults to 1s
+
+	MaxFlushDuration time.Duration // MaxFlushDuration bounds each flush, returning ErrFlushTimeout and keeping the events when exceeded (optional)
+
+	Context map[string]interface{} // Context sent with every event, such as the program version (optional)
+
+	UseXDG bool // UseXDG stores state in $XDG_STATE_HOME/<dir>, or ~/.local/state/<dir>, except on Windows and macOS
+
+	HomeDir func() (string, error) // HomeDir resolves ~, returned by Validate on error, defaults to os.UserHomeDir
+
+	Clock Clock // Clock used for timestamps and flush ages, defaults to the wall clock
+
+	Store Store // Store buffers events in place of ~/<dir>/events, such as NewMemStore() in tests (optional)
+
+	SingleFile bool // SingleFile stores the id, disable flag, flush times and events in ~/<dir>/analytics.json
+
+	Transport Transport // Transport delivers flushed events in place of Segment (optional)
+
+	MaxEvents int // MaxEvents buffered, the oldest events are dropped beyond it (optional)
+
+	MaxBytes int64 // MaxBytes of the events file before it's rotated to events.<n>, bounding each file read (optional)
+
+	IDGenerator func() (string, error) // IDGenerator creates anonymous ids, defaults to random UUIDs
+
+	ValidateEventName func(string) error // ValidateEventName enforces a naming convention, failing Track on error (optional)
+
+	MaxProperties      int  // MaxProperties per event, failing Track with ErrPropertiesTooLarge beyond it (optional)
+	MaxPropertyBytes   int  // MaxPropertyBytes of the JSON encoded properties, failing Track with ErrPropertiesTooLarge beyond it (optional)
+	TruncateProperties bool // TruncateProperties removes properties beyond the limits instead of failing Track
+
+	StrictDecode bool // StrictDecode fails reading events on a malformed line instead of skipping it
+
+	AutoRecover bool // AutoRecover merges events left by an interrupted flush in New, see Recover()
+
+	Codec Codec // Codec encodes the records of the events file, defaults to encoding/json
+
+	Redact func(map[string]interface{}) map[string]interface{} // Redact scrubs properties before they're buffered, see RedactKeys() (optional)
+
+	DryRun     bool // DryRun logs flushed events via Log instead of sending them
+	DryRunKeep bool // DryRunKeep keeps the events buffered after a DryRun flush
+
+	ConsentRequired bool // ConsentRequired makes tracking opt-in, a no-op until GrantConsent()
+
+	ForceEnabledEnv string // ForceEnabledEnv names a variable such as MYAPP_ANALYTICS, "on" or "off" overriding the disable file and consent (optional)
+
+	SampleRate *float64    // SampleRate is the best-effort probability an event is kept, zero drops all and nil keeps all, see WithSampleRate() (optional)
+	RandSource rand.Source // RandSource used for sampling and FlushJitter, defaults to one seeded with the current time
 }
 
 // defaults applies the default values.
@@ -47,271 +333,3799 @@ func (c *Config) defaults() {
 	if c.Log == nil {
 		c.Log = log.Log
 	}
+
+	if c.WriteKeyEnv == "" {
+		c.WriteKeyEnv = "SEGMENT_WRITE_KEY"
+	}
+
+	if c.DirPerm == 0 {
+		c.DirPerm = 0700
+	}
+
+	if c.FilePerm == 0 {
+		c.FilePerm = 0600
+	}
+
+	if c.PriorityFlushSize == 0 {
+		c.PriorityFlushSize = 1
+	}
+
+	if c.FlushBackoff == 0 {
+		c.FlushBackoff = time.Second
+	}
+
+	if c.MaxTrackRate == 0 {
+		c.MaxTrackRate = c.RateLimit
+	}
+
+	if c.HomeDir == nil {
+		c.HomeDir = os.UserHomeDir
+	}
+
+	if c.Clock == nil {
+		c.Clock = wallClock{}
+	}
+
+	if c.IDGenerator == nil {
+		c.IDGenerator = uuid.GenerateUUID
+	}
+
+	if c.Codec == nil {
+		c.Codec = jsonCodec{}
+	}
+
+	if c.RandSource == nil {
+		c.RandSource = rand.NewSource(time.Now().UnixNano())
+	}
 }
 
-// New returns a new analytics tracker with `config`.
-func New(config *Config) *Analytics {
+// Option configures the tracker, as an alternative to setting Config fields.
+type Option func(*Config)
+
+// WithLogger sets the Log used for all of the tracker's logging.
+func WithLogger(l log.Interface) Option {
+	return func(c *Config) {
+		c.Log = l
+	}
+}
+
+// WithSampleRate sets the SampleRate, the probability an event is kept.
+func WithSampleRate(rate float64) Option {
+	return func(c *Config) {
+		c.SampleRate = &rate
+	}
+}
+
+// New returns a new analytics tracker with `config` and `options`. An invalid
+// config, such as a malformed Endpoint, is logged as an error, sent to
+// Lifecycle as StateError, and returned by Validate(), Track and Flush.
+func New(config *Config, options ...Option) *Analytics {
+	for _, o := range options {
+		o(config)
+	}
+
 	config.defaults()
 
 	a := &Analytics{
-		Config: config,
+		Config:    config,
+		countSize: -1,
 	}
 
+	a.SetContext(config.Context)
+
+	a.mu.Lock()
 	a.init()
+	a.unlock()
+
 	return a
 }
 
 // Analytics todo...
 type Analytics struct {
 	*Config
-	root       string
-	userID     string
-	eventsFile *os.File
-	events     *json.Encoder
+	mu           sync.Mutex
+	root         string
+	userID       string
+	anonymousID  string
+	runs         int
+	firstRun     bool
+	limiter      *limiter
+	paused       bool
+	err          error
+	workspace    string
+	globals      map[string]interface{}
+	socket       net.Conn
+	lastEvent    string
+	count        int
+	countSize    int64
+	metrics      Metrics
+	eventsFile   *os.File
+	events       *recordEncoder
+	store        Store
+	aead         cipher.AEAD
+	last         *written
+	random       *rand.Rand
+	bufferLock   *flock.Flock
+	flushLock    *flock.Flock
+	noop         bool
+	calls        []func()
+	staleRetry   time.Time
+	staleBackoff time.Duration
+	flushing     bool
+	flushDone    *sync.Cond
+
+	eventContext map[string]interface{}
+}
+
+// unlock releases the lock and then runs the callbacks deferred
+// by later(), so that they may call back into the tracker.
+func (a *Analytics) unlock() {
+	calls := a.calls
+	a.calls = nil
+	a.mu.Unlock()
+
+	for _, fn := range calls {
+		fn()
+	}
+}
+
+// later defers user callback `fn` until the lock is released.
+func (a *Analytics) later(fn func()) {
+	a.calls = append(a.calls, fn)
 }
 
 // Initialize:
 //
 // - ~/<dir>
+// - ~/<dir>/config.json (read only)
 // - ~/<dir>/id
 // - ~/<dir>/events
 // - ~/<dir>/last_flush
-//
 func (a *Analytics) init() {
-	a.initRoot()
+	if a.noop {
+		return
+	}
+
+	if err := a.setup(); err != nil {
+		a.err = err
+		a.Log.WithError(err).Error("invalid config")
+		a.emit(StateError, err)
+		return
+	}
+
+	a.aead, _ = newAEAD(a.EncryptionKey)
 
 	enabled, err := a.Enabled()
 	if err != nil || !enabled {
 		a.Log.Debug("disabled")
+		a.emit(StateDisabled, nil)
 		return
 	}
 
 	a.initDir()
 	a.initID()
 	a.initEvents()
+	a.initRecover()
+	a.initRuns()
+	a.initFirstRun()
+	a.emit(StateInitialized, nil)
+}
+
+// setup resolves the root directory and merges and validates the config.
+func (a *Analytics) setup() error {
+	if err := a.initRoot(); err != nil {
+		return err
+	}
+
+	if err := a.initWriteKey(); err != nil {
+		return err
+	}
+
+	a.initNamespace()
+	a.initConfigFile()
+	return a.validate()
+}
+
+// Validate returns the error setting up the tracker in New, such as an
+// empty WriteKey or Dir or an unresolved home dir, in which case Track and Flush return it without
+// touching the disk.
+func (a *Analytics) Validate() error {
+	return a.err
 }
 
-// init root directory.
-func (a *Analytics) initRoot() {
-	home, err := homedir.Dir()
+// init root directory. An absolute Dir is used as-is, and a Dir
+// prefixed with "~" is expanded, otherwise it's relative to ~, or
+// to the XDG state directory when UseXDG is set. When the home
+// directory can't be resolved the error is returned by Validate.
+func (a *Analytics) initRoot() error {
+	if a.Dir == "" {
+		return errors.New("dir is required")
+	}
+
+	if filepath.IsAbs(a.Dir) {
+		a.root = a.Dir
+		return nil
+	}
+
+	dir := a.Dir
+	tilde := strings.HasPrefix(dir, "~")
+
+	if tilde {
+		if dir != "~" && !strings.HasPrefix(dir, "~/") && !strings.HasPrefix(dir, `~\`) {
+			return errors.Errorf("cannot expand user-specific home dir %q", dir)
+		}
+
+		dir = strings.TrimLeft(dir[1:], `/\`)
+	}
+
+	home, err := a.HomeDir()
 	if err != nil {
-		a.Log.WithError(err).Debug("error finding home dir")
+		return errors.Wrap(err, "finding home dir")
+	}
+
+	if !tilde && a.UseXDG && runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
+		a.root = filepath.Join(xdgStateHome(home), dir)
+		return nil
+	}
+
+	a.root = filepath.Join(home, dir)
+	return nil
+}
+
+// init ~/<dir>/<namespace> as the root when Namespace is set.
+func (a *Analytics) initNamespace() {
+	if a.Namespace == "" || a.root == "" {
 		return
 	}
-	a.root = filepath.Join(home, a.Dir)
+
+	a.root = filepath.Join(a.root, a.Namespace)
+}
+
+// xdgStateHome returns $XDG_STATE_HOME, defaulting to ~/.local/state.
+func xdgStateHome(home string) string {
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+
+	return filepath.Join(home, ".local", "state")
 }
 
 // init ~/<dir>.
 func (a *Analytics) initDir() {
-	os.Mkdir(a.root, 0755)
+	os.MkdirAll(a.root, a.DirPerm)
 }
 
-// init ~/<dir>/id.
+// init ~/<dir>/id, and ~/<dir>/user_id when set.
 func (a *Analytics) initID() {
-	path := filepath.Join(a.root, "id")
+	if b, err := ioutil.ReadFile(filepath.Join(a.root, "user_id")); err == nil {
+		a.userID = string(b)
+	}
 
-	b, err := ioutil.ReadFile(path)
+	b, err := a.readFile("id")
 	if err == nil {
-		a.userID = string(b)
+		a.anonymousID = parseID(b)
 		a.Log.Debug("id already created")
 		return
 	}
 
+	a.firstRun = os.IsNotExist(err)
 	a.Log.Debug("creating id")
-	id, err := uuid.GenerateUUID()
+	id, err := a.IDGenerator()
 	if err != nil {
 		return
 	}
-	a.userID = string(id)
+	a.anonymousID = string(id)
 
-	err = ioutil.WriteFile(path, []byte(id), 0666)
+	err = a.saveID(id)
 	if err != nil {
 		a.Log.WithError(err).Debug("error saving id")
+		a.initMachineID()
 		return
 	}
 
 	a.Touch()
 }
 
-// init ~/<dir>/events.
-func (a *Analytics) initEvents() {
-	path := filepath.Join(a.root, "events")
+// idFile is the JSON format of ~/<dir>/id.
+type idFile struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int       `json:"version"`
+}
 
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0666)
-	if err != nil {
-		log.WithError(err).Debug("error opening events")
-		return
+// parseID returns the id from the contents of ~/<dir>/id, which is
+// either JSON or the legacy raw id.
+func parseID(b []byte) string {
+	var f idFile
+
+	if err := json.Unmarshal(b, &f); err == nil && f.ID != "" {
+		return f.ID
 	}
-	a.eventsFile = f
 
-	a.events = json.NewEncoder(f)
+	return string(b)
 }
 
-// Enabled returns true if the user hasn't opted out.
-func (a *Analytics) Enabled() (bool, error) {
-	_, err := os.Stat(filepath.Join(a.root, "disable"))
+// saveID writes `id` to ~/<dir>/id, or the raw
+// `id` to the state when SingleFile is set.
+func (a *Analytics) saveID(id string) error {
+	if a.SingleFile {
+		return a.writeFile("id", []byte(id))
+	}
 
-	if os.IsNotExist(err) {
-		return true, nil
+	b, err := json.Marshal(&idFile{
+		ID:        id,
+		CreatedAt: time.Now(),
+		Version:   1,
+	})
+
+	if err != nil {
+		return err
 	}
 
-	return false, err
+	return ioutil.WriteFile(filepath.Join(a.root, "id"), b, a.FilePerm)
 }
 
-// Disable tracking. This method creates ~/<dir>/disable.
-func (a *Analytics) Disable() error {
-	a.Log.Debug("disable")
-	_, err := os.Create(filepath.Join(a.root, "disable"))
-	return err
-}
+// initMachineID falls back to the machine id, so that identity is stable
+// across runs when the id file can't be persisted.
+func (a *Analytics) initMachineID() {
+	id, err := a.MachineID()
+	if err != nil {
+		a.Log.WithError(err).Debug("error creating machine id")
+		return
+	}
 
-// Enable tracking. This method removes ~/<dir>/disable.
-func (a *Analytics) Enable() error {
-	a.Log.Debug("enable")
-	return os.Remove(filepath.Join(a.root, "disable"))
+	a.anonymousID = id
 }
 
-// Events reads the events from disk.
-func (a *Analytics) Events() (v []*Event, err error) {
-	f, err := os.Open(filepath.Join(a.root, "events"))
+// MachineID returns a stable id derived from the hostname and MachineIDSalt,
+// defaulting to Dir. The hostname is hashed so it is not disclosed, and the
+// salt prevents correlating ids across programs.
+func (a *Analytics) MachineID() (string, error) {
+	if a.noop {
+		return "", nil
+	}
+
+	host, err := os.Hostname()
 	if err != nil {
-		return nil, errors.Wrap(err, "opening")
+		return "", errors.Wrap(err, "hostname")
 	}
 
-	dec := json.NewDecoder(f)
+	salt := a.MachineIDSalt
+	if salt == "" {
+		salt = a.Dir
+	}
 
-	for {
-		var e Event
-		err := dec.Decode(&e)
+	h := sha256.Sum256([]byte(salt + ":" + host))
+	return hex.EncodeToString(h[:]), nil
+}
 
-		if err == io.EOF {
-			break
-		}
+// RotateID replaces the anonymous id with a freshly generated one, buffering
+// an alias from the previous id so that Segment links the two. Events buffered
+// before the rotation are flushed with the new id. This method is a no-op
+// when tracking is disabled, leaving the id untouched.
+func (a *Analytics) RotateID() (string, error) {
+	if a.noop {
+		return "", nil
+	}
 
-		if err != nil {
-			return nil, errors.Wrap(err, "decoding")
-		}
+	a.mu.Lock()
+	defer a.unlock()
+	a.waitFlush()
 
-		v = append(v, &e)
+	if a.disabled() {
+		return "", nil
 	}
 
-	return v, nil
-}
+	id, err := a.IDGenerator()
+	if err != nil {
+		return "", errors.Wrap(err, "generating id")
+	}
 
-// Size returns the number of events.
-func (a *Analytics) Size() (int, error) {
-	events, err := a.Events()
+	err = a.saveID(id)
 	if err != nil {
-		return 0, errors.Wrap(err, "reading events")
+		return "", errors.Wrap(err, "saving id")
 	}
 
-	return len(events), nil
-}
+	prev := a.anonymousID
+	a.anonymousID = id
+	a.Log.WithField("previous", prev).Debug("rotated id")
 
-// Touch ~/<dir>/last_flush.
-func (a *Analytics) Touch() error {
-	path := filepath.Join(a.root, "last_flush")
-	return ioutil.WriteFile(path, []byte(":)"), 0755)
-}
+	if prev == "" {
+		return id, nil
+	}
+
+	err = a.write(&Event{
+		Type:       TypeAlias,
+		PreviousID: prev,
+		UserID:     id,
+		Timestamp:  a.Clock.Now(),
+	})
 
-// LastFlush returns the last flush time.
-func (a *Analytics) LastFlush() (time.Time, error) {
-	info, err := os.Stat(filepath.Join(a.root, "last_flush"))
 	if err != nil {
-		return time.Unix(0, 0), err
+		return "", errors.Wrap(err, "buffering alias")
 	}
 
-	return info.ModTime(), nil
+	return id, nil
 }
 
-// LastFlushDuration returns the last flush time delta.
-func (a *Analytics) LastFlushDuration() (time.Duration, error) {
-	lastFlush, err := a.LastFlush()
-	if err != nil {
-		return 0, nil
+// UserID returns the id events are attributed to, being the id set by
+// SetUserID() or otherwise the anonymous id in ~/<dir>/id. An empty
+// string is returned when tracking is disabled.
+func (a *Analytics) UserID() string {
+	a.mu.Lock()
+	defer a.unlock()
+
+	if a.disabled() {
+		return ""
+	}
+
+	if a.userID != "" {
+		return a.userID
 	}
 
-	return time.Now().Sub(lastFlush), nil
+	return a.anonymousID
 }
 
-// Track event `name` with optional `props`.
-func (a *Analytics) Track(name string, props map[string]interface{}) error {
-	if a.events == nil {
+// SetUserID sets the user id to `id`, such as the account id of a user who
+// has logged in, saving it to ~/<dir>/user_id. Events are sent with the user
+// id alongside the anonymous id, letting Segment link the two. Events buffered
+// before the change are reassigned, being flushed with the new user id.
+func (a *Analytics) SetUserID(id string) error {
+	if a.noop {
 		return nil
 	}
 
-	return a.events.Encode(&Event{
-		Event:      name,
-		Properties: props,
-	})
-}
+	a.mu.Lock()
+	defer a.unlock()
+	a.waitFlush()
 
-// ConditionalFlush flushes if event count is above `aboveSize`, or age is `aboveDuration`,
-// otherwise Close() is called and the underlying file(s) are closed.
-func (a *Analytics) ConditionalFlush(aboveSize int, aboveDuration time.Duration) error {
-	age, err := a.LastFlushDuration()
-	if err != nil {
-		return err
+	if id == "" {
+		return errors.New("empty id")
 	}
 
-	size, err := a.Size()
-	if err != nil {
-		return err
+	path := filepath.Join(a.root, "user_id")
+
+	if err := ioutil.WriteFile(path, []byte(id), a.FilePerm); err != nil {
+		return errors.Wrap(err, "saving user id")
 	}
 
-	ctx := a.Log.WithFields(log.Fields{
-		"age":            age,
-		"size":           size,
-		"above_size":     aboveSize,
-		"above_duration": aboveDuration,
-	})
+	a.Log.WithField("previous", a.userID).Debug("set user id")
+	a.userID = id
+	return nil
+}
 
-	switch {
-	case size >= aboveSize:
-		ctx.Debug("flush size")
-		return a.Flush()
-	case age >= aboveDuration:
+// Alias links the anonymous id to `userID`, such as after the user logs in,
+// by buffering an alias which is sent ahead of later events on flush. Call
+// SetUserID to attribute subsequent events to `userID`. This method is a
+// no-op when tracking is disabled.
+func (a *Analytics) Alias(userID string) error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	if userID == "" {
+		return errors.New("empty id")
+	}
+
+	if a.disabled() {
+		return nil
+	}
+
+	err := a.write(&Event{
+		Type:       TypeAlias,
+		PreviousID: a.anonymousID,
+		UserID:     userID,
+		Timestamp:  a.Clock.Now(),
+	})
+
+	if err != nil {
+		return storageError(errors.Wrap(err, "buffering alias"))
+	}
+
+	return nil
+}
+
+// Reset wipes the local state for a clean slate, removing the buffered
+// events, the flush markers, the user id and traits, and generating a
+// fresh anonymous id unless tracking is disabled. The Metrics are zeroed.
+// The disable, consent, and config files are kept.
+func (a *Analytics) Reset() error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+	a.waitFlush()
+
+	if err := a.closeEvents(); err != nil {
+		return errors.Wrap(err, "closing")
+	}
+
+	if a.store != nil {
+		if err := a.store.Reset(); err != nil {
+			return errors.Wrap(err, "resetting store")
+		}
+	}
+
+	if a.SingleFile {
+		err := a.updateState(func(s *state) {
+			s.LastFlush = nil
+			s.LastFlushSuccess = nil
+		})
+
+		if err != nil {
+			return errors.Wrap(err, "resetting state")
+		}
+	}
+
+	names := []string{
+		"events",
+		"events.flushing",
+		"priority",
+		"priority.flushing",
+		"last_flush",
+		"last_flush_success",
+		"last_event",
+		"runs",
+		"seq",
+		"traits",
+		"user_id",
+	}
+
+	for _, name := range names {
+		err := os.Remove(filepath.Join(a.root, name))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "removing %s", name)
+		}
+	}
+
+	rotated, err := a.rotated()
+	if err != nil {
+		return errors.Wrap(err, "listing rotated events")
+	}
+
+	if err := removeRotated(rotated); err != nil {
+		return errors.Wrap(err, "removing rotated events")
+	}
+
+	a.userID = ""
+	a.lastEvent = ""
+	a.metrics = Metrics{}
+	a.runs = 0
+	a.countSize = -1
+
+	if a.disabled() {
+		return nil
+	}
+
+	id, err := a.IDGenerator()
+	if err != nil {
+		return errors.Wrap(err, "generating id")
+	}
+
+	if err := a.saveID(id); err != nil {
+		return errors.Wrap(err, "saving id")
+	}
+
+	a.anonymousID = id
+	a.initEvents()
+	return nil
+}
+
+// initFirstRun tracks the "first_run" event when enabled, and calls
+// OnFirstRun once the lock is released.
+func (a *Analytics) initFirstRun() {
+	if !a.firstRun {
+		return
+	}
+
+	if a.OnFirstRun != nil {
+		a.later(a.OnFirstRun)
+	}
+
+	if !a.TrackFirstRun {
+		return
+	}
+
+	if _, err := a.trackLocked("first_run", nil, nil); err != nil {
+		a.Log.WithError(err).Debug("error tracking first run")
+	}
+}
+
+// FirstRun returns true if this is the first invocation, that
+// is the id file did not exist.
+func (a *Analytics) FirstRun() bool {
+	a.mu.Lock()
+	defer a.unlock()
+	return a.firstRun
+}
+
+// IsFirstRun is an alias of FirstRun.
+//
+// Deprecated: use FirstRun.
+func (a *Analytics) IsFirstRun() bool {
+	return a.FirstRun()
+}
+
+// init recovery of leftover events when AutoRecover is set.
+func (a *Analytics) initRecover() {
+	if !a.AutoRecover || a.store != nil {
+		return
+	}
+
+	if err := a.recover(); err != nil {
+		a.Log.WithError(err).Debug("error recovering events")
+	}
+}
+
+// init ~/<dir>/runs, counting this invocation.
+func (a *Analytics) initRuns() {
+	if a.FlushEveryNRuns <= 0 {
+		return
+	}
+
+	path := filepath.Join(a.root, "runs")
+	b, _ := ioutil.ReadFile(path)
+	n, _ := strconv.Atoi(string(b))
+	a.runs = n + 1
+
+	err := ioutil.WriteFile(path, []byte(strconv.Itoa(a.runs)), a.FilePerm)
+	if err != nil {
+		a.Log.WithError(err).Debug("error saving runs")
+	}
+}
+
+// RunCount returns the number of runs since the last flush, this is
+// only maintained when FlushEveryNRuns is set.
+func (a *Analytics) RunCount() int {
+	a.mu.Lock()
+	defer a.unlock()
+	return a.runs
+}
+
+// init ~/<dir>/events, or the Store when set.
+func (a *Analytics) initEvents() {
+	if a.Store != nil {
+		a.store = a.Store
+		return
+	}
+
+	a.initLocks()
+
+	if a.SingleFile {
+		a.store = &stateStore{a: a}
+		return
+	}
+
+	if err := a.openEvents(); err != nil {
+		a.Log.WithError(err).Debug("error opening events")
+		a.storageUnavailable(err)
+	}
+}
+
+// storageUnavailable handles ~/<dir> not being writable, falling
+// back to an in-memory buffer when enabled.
+func (a *Analytics) storageUnavailable(err error) {
+	if a.OnStorageUnavailable != nil {
+		a.later(func() { a.OnStorageUnavailable(err) })
+	}
+
+	if !a.FallbackInMemory {
+		return
+	}
+
+	if a.store == nil {
+		a.Log.Debug("buffering events in memory")
+		a.store = NewMemStore()
+	}
+}
+
+// disabled returns true when tracking is disabled,
+// or when there's nowhere to buffer events.
+func (a *Analytics) disabled() bool {
+	return a.events == nil && a.store == nil
+}
+
+// openEvents opens ~/<dir>/events for appending, closing
+// the previous handle so that it isn't leaked.
+func (a *Analytics) openEvents() error {
+	path := filepath.Join(a.root, "events")
+
+	if err := a.closeEvents(); err != nil {
+		a.Log.WithError(err).Debug("error closing events")
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, a.FilePerm)
+	if err != nil {
+		return err
+	}
+	a.eventsFile = f
+
+	a.events = a.encoder(f)
+
+	if a.EventsHeader {
+		return a.writeHeader(f, a.events)
+	}
+
+	return nil
+}
+
+// writeHeader writes the header to the empty events file `f`.
+func (a *Analytics) writeHeader(f *os.File, enc *recordEncoder) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() > 0 {
+		return nil
+	}
+
+	return enc.Encode(&record{
+		Header: &Header{
+			Version:   HeaderVersion,
+			CreatedAt: time.Now(),
+			ID:        a.anonymousID,
+		},
+	})
+}
+
+// Reopen closes and reopens the events file, for example after it
+// has been rotated or truncated by external tooling. This method is a
+// no-op when tracking is disabled.
+func (a *Analytics) Reopen() error {
+	a.mu.Lock()
+	defer a.unlock()
+
+	if a.events == nil || a.store != nil {
+		return nil
+	}
+
+	a.closeEvents()
+	a.events = nil
+
+	return a.openEvents()
+}
+
+// Enabled returns true if the user hasn't opted out, or if the
+// expiry of a DisableUntil() has passed. Setting the DO_NOT_TRACK
+// environment variable to "1" or "true" opts out as well. When
+// ConsentRequired is set the user must also have opted in with
+// GrantConsent(). The ForceEnabledEnv variable set to "on" or "off"
+// overrides the disable file and consent, but not DO_NOT_TRACK.
+func (a *Analytics) Enabled() (bool, error) {
+	if a.noop {
+		return false, nil
+	}
+
+	if doNotTrack() {
+		return false, nil
+	}
+
+	switch a.forced() {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+
+	if !a.consentGranted() {
+		return false, nil
+	}
+
+	return a.enabledByFile()
+}
+
+// consentGranted returns false when ConsentRequired is set and
+// ~/<dir>/enable doesn't exist.
+func (a *Analytics) consentGranted() bool {
+	if !a.ConsentRequired {
+		return true
+	}
+
+	_, err := os.Stat(filepath.Join(a.root, "enable"))
+	return err == nil
+}
+
+// enabledByFile returns false when ~/<dir>/disable exists and hasn't expired.
+func (a *Analytics) enabledByFile() (bool, error) {
+	b, err := a.readFile("disable")
+
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	until, err := time.Parse(time.RFC3339, string(b))
+	if err != nil {
+		return false, nil
+	}
+
+	if a.Clock.Now().Before(until) {
+		return false, nil
+	}
+
+	a.Log.WithField("until", until).Debug("disable expired")
+	a.removeFile("disable")
+	return true, nil
+}
+
+// forced returns "on" or "off" when the ForceEnabledEnv
+// variable overrides the disable file and consent.
+func (a *Analytics) forced() string {
+	if a.ForceEnabledEnv == "" {
+		return ""
+	}
+
+	switch v := strings.ToLower(os.Getenv(a.ForceEnabledEnv)); v {
+	case "on", "off":
+		return v
+	default:
+		return ""
+	}
+}
+
+// doNotTrack returns true when the DO_NOT_TRACK environment variable opts out.
+func doNotTrack() bool {
+	switch strings.ToLower(os.Getenv("DO_NOT_TRACK")) {
+	case "1", "true":
+		return true
+	default:
+		return false
+	}
+}
+
+// EffectiveConfig describes the resolved settings of a tracker.
+type EffectiveConfig struct {
+	Root           string // Root directory used for state
+	Enabled        bool   // Enabled is true when tracking is active
+	DisabledByFile bool   // DisabledByFile is true when ~/<dir>/disable exists
+	DisabledByEnv  bool   // DisabledByEnv is true when DO_NOT_TRACK is set
+
+	DisabledByConsent bool   // DisabledByConsent is true when ConsentRequired is set without GrantConsent()
+	Forced            string // Forced is "on" or "off" when ForceEnabledEnv overrides the disable file and consent
+	ConsentRequired   bool   // ConsentRequired is true when tracking is opt-in
+	ConsentGranted    bool   // ConsentGranted is true when the user opted in with GrantConsent()
+
+	DisabledUntil time.Time // DisabledUntil is the expiry set by DisableUntil(), if any
+
+	DryRun   bool   // DryRun is true when flushed events are logged instead of sent
+	Endpoint string // Endpoint events are sent to, defaulting to the Segment API
+}
+
+// EffectiveConfig returns the resolved settings, useful for explaining
+// to users why analytics is or isn't sending, such as in a status command.
+func (a *Analytics) EffectiveConfig() EffectiveConfig {
+	if a.noop {
+		return EffectiveConfig{}
+	}
+
+	c := EffectiveConfig{
+		Root:     a.root,
+		DryRun:   a.DryRun,
+		Endpoint: a.endpoint(),
+	}
+
+	enabled, _ := a.enabledByFile()
+	c.DisabledByFile = !enabled
+	c.DisabledByEnv = doNotTrack()
+	c.DisabledByConsent = !a.consentGranted()
+	c.ConsentRequired = a.ConsentRequired
+	_, err := os.Stat(filepath.Join(a.root, "enable"))
+	c.ConsentGranted = err == nil
+	c.Forced = a.forced()
+	c.Enabled = enabled && !c.DisabledByConsent
+
+	if c.Forced != "" {
+		c.Enabled = c.Forced == "on"
+	}
+
+	c.Enabled = c.Enabled && !c.DisabledByEnv
+
+	b, _ := a.readFile("disable")
+	c.DisabledUntil, _ = time.Parse(time.RFC3339, string(b))
+
+	return c
+}
+
+// Disable tracking, returning true if it was not already disabled.
+// This method creates ~/<dir>/disable.
+func (a *Analytics) Disable() (bool, error) {
+	if a.noop {
+		return false, nil
+	}
+
+	b, err := a.readFile("disable")
+	if err == nil && len(b) == 0 {
+		return false, nil
+	}
+
+	a.Log.Debug("disable")
+
+	if err := a.writeFile("disable", nil); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Drain flushes the buffered events regardless of MinEventsToFlush and
+// then disables tracking, such as when the user opts out. When the flush
+// fails the events are kept and tracking remains enabled.
+func (a *Analytics) Drain() error {
+	a.mu.Lock()
+	defer a.unlock()
+
+	if err := a.flush(context.Background(), true); err != nil {
+		return err
+	}
+
+	_, err := a.Disable()
+	return err
+}
+
+// DisableUntil disables tracking until `t`. This method creates
+// ~/<dir>/disable containing the expiry, after which Enabled()
+// removes it.
+func (a *Analytics) DisableUntil(t time.Time) error {
+	if a.noop {
+		return nil
+	}
+
+	a.Log.WithField("until", t).Debug("disable until")
+	return a.writeFile("disable", []byte(t.Format(time.RFC3339)))
+}
+
+// Enable tracking, returning true if it was disabled.
+// This method removes ~/<dir>/disable.
+func (a *Analytics) Enable() (bool, error) {
+	if a.noop {
+		return false, nil
+	}
+
+	err := a.removeFile("disable")
+
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	a.Log.Debug("enable")
+	return true, nil
+}
+
+// GrantConsent opts in to tracking when ConsentRequired is set, starting
+// the tracker if it was disabled. This method creates ~/<dir>/enable.
+func (a *Analytics) GrantConsent() error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	a.Log.Debug("grant consent")
+
+	if err := os.MkdirAll(a.root, a.DirPerm); err != nil {
+		return errors.Wrap(err, "creating dir")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(a.root, "enable"), nil, a.FilePerm); err != nil {
+		return err
+	}
+
+	if a.disabled() {
+		a.init()
+	}
+
+	return nil
+}
+
+// RevokeConsent opts out of tracking when ConsentRequired is set, making
+// Track a no-op. Buffered events are kept, see DeleteEvents(). This method
+// removes ~/<dir>/enable.
+func (a *Analytics) RevokeConsent() error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	a.Log.Debug("revoke consent")
+	err := os.Remove(filepath.Join(a.root, "enable"))
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if !a.ConsentRequired {
+		return nil
+	}
+
+	if err := a.closeEvents(); err != nil {
+		return errors.Wrap(err, "closing events")
+	}
+
+	a.eventsFile = nil
+	a.events = nil
+	a.store = nil
+
+	return nil
+}
+
+// ConsentCategory records the user's consent to tracking events in
+// category `name`. This method creates ~/<dir>/consent/<name>.
+func (a *Analytics) ConsentCategory(name string) error {
+	if a.noop {
+		return nil
+	}
+
+	a.Log.WithField("category", name).Debug("consent category")
+	dir := filepath.Join(a.root, "consent")
+
+	if err := os.MkdirAll(dir, a.DirPerm); err != nil {
+		return errors.Wrap(err, "creating consent dir")
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, name), nil, a.FilePerm)
+}
+
+// RevokeCategory revokes consent for category `name`. This method
+// removes ~/<dir>/consent/<name>.
+func (a *Analytics) RevokeCategory(name string) error {
+	if a.noop {
+		return nil
+	}
+
+	a.Log.WithField("category", name).Debug("revoke category")
+	err := os.Remove(filepath.Join(a.root, "consent", name))
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// consented returns true if event `name` may be tracked with respect
+// to category consent, which ForceEnabledEnv set to "on" overrides.
+func (a *Analytics) consented(name string) bool {
+	if !a.RequireCategoryConsent || a.forced() == "on" {
+		return true
+	}
+
+	category, ok := a.Categories[name]
+	if !ok {
+		return true
+	}
+
+	_, err := os.Stat(filepath.Join(a.root, "consent", category))
+	return err == nil
+}
+
+// Events reads the events from disk, none are returned when the events
+// file doesn't exist, such as when tracking is disabled. Events buffered for
+// longer than MaxRetryAge or older than EventTTL are excluded, as Flush would
+// drop them.
+func (a *Analytics) Events() ([]*Event, error) {
+	if a.noop {
+		return nil, nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	events, err := a.readEvents()
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	var v []*Event
+
+	for _, e := range events {
+		if !a.expired(e) && !a.stale(e) {
+			v = append(v, e)
+		}
+	}
+
+	return v, nil
+}
+
+// Snapshot returns the buffered events without consuming them, for example
+// to show the user what's pending upload. The events are deep copies,
+// modifying them or their properties does not change the buffer.
+func (a *Analytics) Snapshot() ([]*Event, error) {
+	events, err := a.Events()
+	if err != nil {
+		return nil, err
+	}
+
+	v := make([]*Event, len(events))
+
+	for i, e := range events {
+		v[i] = copyEvent(e)
+	}
+
+	return v, nil
+}
+
+// copyEvent returns a deep copy of event `e`.
+func copyEvent(e *Event) *Event {
+	c := *e
+	c.Properties = copyMap(e.Properties)
+	c.Traits = copyMap(e.Traits)
+	c.Integrations = copyMap(e.Integrations)
+	return &c
+}
+
+// copyMap returns a deep copy of `m`, copying nested maps and slices.
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+
+	c := make(map[string]interface{}, len(m))
+
+	for k, v := range m {
+		c[k] = copyValue(v)
+	}
+
+	return c
+}
+
+// copyValue returns a deep copy of `v` when it's a map or slice.
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return copyMap(v)
+	case []interface{}:
+		c := make([]interface{}, len(v))
+
+		for i, item := range v {
+			c[i] = copyValue(item)
+		}
+
+		return c
+	default:
+		return v
+	}
+}
+
+// PendingByName returns the number of buffered track events
+// by name, including high priority events.
+func (a *Analytics) PendingByName() (map[string]int, error) {
+	if a.noop {
+		return nil, nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	events, err := a.readEvents()
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	high, err := a.priorityEvents()
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	counts := make(map[string]int)
+
+	for _, e := range append(high, events...) {
+		if e.Type == "" || e.Type == TypeTrack {
+			counts[e.Event]++
+		}
+	}
+
+	return counts, nil
+}
+
+// readEvents returns the buffered events, which are
+// empty when the events file doesn't exist.
+func (a *Analytics) readEvents() ([]*Event, error) {
+	if a.store != nil {
+		return a.store.ReadAll()
+	}
+
+	r, err := a.reader()
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "opening")
+	}
+
+	defer r.Close()
+	return a.decodeAll(r)
+}
+
+// EachEvent calls `fn` with each buffered event in order, decoding them one
+// at a time rather than reading them all into memory. Iteration stops when
+// `fn` returns an error, which is returned. The buffer is locked during
+// iteration, so `fn` must not call methods of Analytics.
+func (a *Analytics) EachEvent(fn func(*Event) error) error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	var ferr error
+
+	err := a.eachEvent(func(e *Event) error {
+		ferr = fn(e)
+		return ferr
+	})
+
+	if ferr != nil {
+		return ferr
+	}
+
+	return storageError(err)
+}
+
+// eachEvent calls `fn` with each buffered event.
+func (a *Analytics) eachEvent(fn func(*Event) error) error {
+	if a.store != nil {
+		events, err := a.store.ReadAll()
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	r, err := a.reader()
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "opening")
+	}
+
+	defer r.Close()
+	return a.decodeEach(r, fn)
+}
+
+// decodeAll decodes the events of `r`.
+func (a *Analytics) decodeAll(r io.Reader) (v []*Event, err error) {
+	err = a.decodeEach(r, func(e *Event) error {
+		v = append(v, e)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// decodeEach calls `fn` with each event decoded from `r` line by line.
+// Malformed lines and a truncated trailing line, such as one left by a
+// process killed mid-write, are skipped so that the valid events may still
+// be flushed, unless StrictDecode is set.
+func (a *Analytics) decodeEach(r io.Reader, fn func(*Event) error) error {
+	br := bufio.NewReader(a.plaintext(r))
+
+	for {
+		line, err := br.ReadBytes('\n')
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var r record
+
+			if err := a.Codec.Decode(line, &r); err != nil {
+				if a.StrictDecode {
+					return errors.Wrap(err, "decoding")
+				}
+
+				a.Log.WithError(err).Debug("skipping malformed event")
+			} else if r.Header == nil {
+				if err := fn(&r.Event); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err == io.ErrUnexpectedEOF && !a.StrictDecode {
+			a.Log.Debug("skipping truncated event")
+			return nil
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "reading")
+		}
+	}
+}
+
+// reader returns a reader of the events file, preceded
+// by the rotated files when MaxBytes is set.
+func (a *Analytics) reader() (io.ReadCloser, error) {
+	path := filepath.Join(a.root, "events")
+
+	paths, err := a.rotated()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return os.Open(path)
+	}
+
+	r := &rotatedReader{}
+	var readers []io.Reader
+
+	for _, path := range append(paths, path) {
+		f, err := os.Open(path)
+
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+
+		r.files = append(r.files, f)
+		readers = append(readers, f)
+	}
+
+	r.Reader = io.MultiReader(readers...)
+	return r, nil
+}
+
+// Size returns the number of events, zero when the events file
+// doesn't exist, such as when tracking is disabled.
+func (a *Analytics) Size() (int, error) {
+	if a.noop {
+		return 0, nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+	return a.size()
+}
+
+// size returns the number of events. The count is cached, and is only
+// re-read when the size of the buffer files no longer matches, such as
+// after it's modified by another process.
+func (a *Analytics) size() (int, error) {
+	if a.store != nil {
+		return a.store.Size()
+	}
+
+	total, serr := a.bufferSize()
+	if serr == nil && total == a.countSize {
+		return a.count, nil
+	}
+
+	n := 0
+
+	err := a.eachEvent(func(*Event) error {
+		n++
+		return nil
+	})
+
+	if err != nil {
+		return 0, errors.Wrap(err, "reading events")
+	}
+
+	a.count = n
+	a.countSize = -1
+
+	if serr == nil {
+		a.countSize = total
+	}
+
+	return n, nil
+}
+
+// Touch ~/<dir>/last_flush.
+func (a *Analytics) Touch() error {
+	if a.noop {
+		return nil
+	}
+
+	return a.touch("last_flush")
+}
+
+// touch ~/<dir>/<name>.
+func (a *Analytics) touch(name string) error {
+	path := filepath.Join(a.root, name)
+	now := a.Clock.Now()
+
+	if a.SingleFile {
+		return a.writeFile(name, []byte(now.Format(time.RFC3339Nano)))
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, a.FilePerm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write([]byte(now.Format(time.RFC3339Nano))); err != nil {
+		f.Close()
+		return err
+	}
+
+	if a.Durability >= DurabilityOnFlush {
+		if err := syncFile(f); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Chtimes(path, now, now)
+}
+
+// touched returns the time ~/<dir>/<name> was touched, as written to the
+// file, falling back to its mtime for files written by older versions.
+func (a *Analytics) touched(name string) (time.Time, error) {
+	path := filepath.Join(a.root, name)
+
+	b, err := a.readFile(name)
+	if err != nil {
+		return time.Unix(0, 0), err
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, string(b)); err == nil {
+		return t, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Unix(0, 0), err
+	}
+
+	return info.ModTime(), nil
+}
+
+// LastFlush returns the last flush time.
+func (a *Analytics) LastFlush() (time.Time, error) {
+	if a.noop {
+		return time.Time{}, nil
+	}
+
+	return a.touched("last_flush")
+}
+
+// LastFlushDuration returns the last flush time delta, which is
+// zero when the last flush is in the future due to clock skew.
+func (a *Analytics) LastFlushDuration() (time.Duration, error) {
+	if a.noop {
+		return 0, nil
+	}
+
+	lastFlush, err := a.LastFlush()
+	if err != nil {
+		return 0, err
+	}
+
+	return nonNegative(a.Clock.Now().Sub(lastFlush)), nil
+}
+
+// NextFlushIn returns how long until a flush is due every `interval`,
+// such as to show when analytics will next sync. Zero is returned
+// when a flush is due now, or has never been attempted.
+func (a *Analytics) NextFlushIn(interval time.Duration) (time.Duration, error) {
+	if a.noop {
+		return 0, nil
+	}
+
+	age, err := a.LastFlushDuration()
+
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if age >= interval {
+		return 0, nil
+	}
+
+	return interval - age, nil
+}
+
+// LastFlushSuccess returns the time of the last successful flush. Unlike
+// LastFlush() this is not updated by failed attempts, falling back to
+// LastFlush() until a flush has succeeded.
+func (a *Analytics) LastFlushSuccess() (time.Time, error) {
+	if a.noop {
+		return time.Time{}, nil
+	}
+
+	t, err := a.touched("last_flush_success")
+	if os.IsNotExist(err) {
+		return a.LastFlush()
+	}
+
+	return t, err
+}
+
+// LastFlushSuccessDuration returns the last successful flush time delta,
+// which is zero when the last flush is in the future due to clock skew.
+func (a *Analytics) LastFlushSuccessDuration() (time.Duration, error) {
+	if a.noop {
+		return 0, nil
+	}
+
+	lastFlush, err := a.LastFlushSuccess()
+	if err != nil {
+		return 0, err
+	}
+
+	return nonNegative(a.Clock.Now().Sub(lastFlush)), nil
+}
+
+// nonNegative returns `d`, or zero when negative.
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
+
+// flushAge returns the time since the last successful flush, which
+// is infinite when ~/<dir>/last_flush is missing, or in the future
+// due to clock skew so that the buffer isn't kept until the clock
+// catches up.
+func (a *Analytics) flushAge() (time.Duration, error) {
+	lastFlush, err := a.LastFlushSuccess()
+
+	if os.IsNotExist(err) {
+		return math.MaxInt64, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	age := a.Clock.Now().Sub(lastFlush)
+
+	if age < 0 {
+		a.Log.WithField("last_flush", lastFlush).Debug("last flush in the future, clock skew")
+		return math.MaxInt64, nil
+	}
+
+	return age, nil
+}
+
+// SetWorkspace tags subsequently tracked events with a "workspace_id"
+// property of `id`, an empty `id` removes the tag.
+func (a *Analytics) SetWorkspace(id string) {
+	a.mu.Lock()
+	defer a.unlock()
+	a.workspace = id
+}
+
+// SetGlobalProperties sets properties merged into every subsequently
+// tracked event, with the properties passed to Track taking precedence.
+func (a *Analytics) SetGlobalProperties(props map[string]interface{}) {
+	a.mu.Lock()
+	defer a.unlock()
+
+	a.globals = make(map[string]interface{})
+
+	for k, v := range props {
+		a.globals[k] = v
+	}
+}
+
+// Track event `name` with optional `props`.
+func (a *Analytics) Track(name string, props map[string]interface{}) error {
+	_, err := a.TrackEvent(name, props)
+	return err
+}
+
+// TrackEvent tracks event `name` with optional `props`, returning the event
+// as it was written to disk. A nil event is returned when it was dropped.
+func (a *Analytics) TrackEvent(name string, props map[string]interface{}) (*Event, error) {
+	return a.track(name, props, nil)
+}
+
+// TrackAnonymous tracks event `name` with optional `props`, always sending
+// it with only the anonymous id, even when a user id is set.
+func (a *Analytics) TrackAnonymous(name string, props map[string]interface{}) error {
+	_, err := a.track(name, props, func(e *Event) {
+		e.Anonymous = true
+	})
+
+	return err
+}
+
+// TrackAt tracks event `name` with optional `props` as having occurred at
+// `ts`, such as for events reconstructed from the log of a previous run. A
+// zero `ts` is treated as now.
+func (a *Analytics) TrackAt(name string, props map[string]interface{}, ts time.Time) error {
+	_, err := a.track(name, props, func(e *Event) {
+		if !ts.IsZero() {
+			e.Timestamp = ts
+		}
+	})
+
+	return err
+}
+
+// TrackWithIntegrations tracks event `name` with optional `props`, sent only
+// to the destinations enabled by `integrations`, such as {"All": false,
+// "Amplitude": true}. The integrations are buffered with the event, and
+// take precedence over Config.Integrations.
+func (a *Analytics) TrackWithIntegrations(name string, props, integrations map[string]interface{}) error {
+	_, err := a.track(name, props, func(e *Event) {
+		e.Integrations = integrations
+	})
+
+	return err
+}
+
+// integrations returns the integrations of track event `e`,
+// defaulting to Config.Integrations.
+func (a *Analytics) integrations(e *Event) map[string]interface{} {
+	if e.Integrations != nil {
+		return e.Integrations
+	}
+
+	return a.Integrations
+}
+
+// track event `name` with optional `props`, applying `fn` to the
+// event before it's written, when present.
+func (a *Analytics) track(name string, props map[string]interface{}, fn func(*Event)) (*Event, error) {
+	a.mu.Lock()
+	defer a.unlock()
+	return a.trackLocked(name, props, fn)
+}
+
+// TrackBatch tracks `events` in order, holding the lock for the whole
+// batch so that concurrent Track calls don't interleave with it. Each
+// event's name and properties are tracked as with Track, keeping its
+// Timestamp, Anonymous, Priority and Integrations when set.
+func (a *Analytics) TrackBatch(events []*Event) error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	for _, e := range events {
+		e := e
+
+		_, err := a.trackLocked(e.Event, e.Properties, func(v *Event) {
+			if !e.Timestamp.IsZero() {
+				v.Timestamp = e.Timestamp
+			}
+
+			v.Anonymous = e.Anonymous
+			v.Priority = e.Priority
+			v.Integrations = e.Integrations
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// trackLocked implementation, the lock must be held.
+func (a *Analytics) trackLocked(name string, props map[string]interface{}, fn func(*Event)) (*Event, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+
+	if a.disabled() {
+		return nil, nil
+	}
+
+	if a.disabledEvent(name) {
+		a.drop(&Event{Event: name, Properties: props}, ErrEventDisabled)
+		return nil, nil
+	}
+
+	if !a.consented(name) {
+		a.drop(&Event{Event: name, Properties: props}, ErrNoConsent)
+		return nil, nil
+	}
+
+	if a.MaxTrackRate > 0 && !a.allow() {
+		a.drop(&Event{Event: name, Properties: props}, ErrRateLimited)
+		return nil, nil
+	}
+
+	if !a.sampled() {
+		a.drop(&Event{Event: name, Properties: props}, ErrSampled)
+		return nil, nil
+	}
+
+	e, err := a.event(name, props)
+	if err != nil {
+		return nil, err
+	}
+
+	if fn != nil {
+		fn(e)
+	}
+
+	if a.TrackPreviousEvent {
+		if prev := a.previousEvent(); prev != "" {
+			e.Properties = set(e.Properties, "previous_event", prev)
+		}
+	}
+
+	if a.Dedup {
+		v, err := a.dedup(e)
+		if err != nil {
+			return nil, storageError(errors.Wrap(err, "deduplicating"))
+		}
+
+		if v != nil {
+			a.metrics.Tracked++
+			return v, nil
+		}
+	}
+
+	if a.SequenceProperty != "" {
+		n, err := a.sequence()
+		if err != nil {
+			return nil, storageError(errors.Wrap(err, "sequencing"))
+		}
+
+		e.Properties = set(e.Properties, a.SequenceProperty, n)
+	}
+
+	if err := a.write(e); err != nil {
+		return nil, storageError(err)
+	}
+
+	a.metrics.Tracked++
+
+	if a.MaxEvents > 0 {
+		if err := a.trim(); err != nil {
+			return e, errors.Wrap(err, "trimming")
+		}
+	}
+
+	if a.TrackPreviousEvent {
+		a.savePreviousEvent(name)
+	}
+
+	if a.MaxBufferAge > 0 {
+		a.flushStale()
+	}
+
+	if a.flushOnEvent(name) {
+		a.Log.WithField("event", name).Debug("flush event")
+
+		if err := a.flushAndReopen(); err != nil {
+			return e, errors.Wrap(err, "flushing")
+		}
+	}
+
+	return e, nil
+}
+
+// flushStale flushes when the oldest buffered event exceeds MaxBufferAge.
+// The flush is best-effort: failures are logged and retried after a
+// backoff doubling from FlushBackoff, rather than on every Track.
+func (a *Analytics) flushStale() {
+	now := a.Clock.Now()
+	if now.Before(a.staleRetry) {
+		return
+	}
+
+	oldest, err := a.oldest()
+	if err != nil {
+		a.Log.WithError(err).Debug("error reading oldest event")
+		return
+	}
+
+	if oldest.IsZero() || now.Sub(oldest) < a.MaxBufferAge {
+		return
+	}
+
+	a.Log.WithField("oldest", oldest).Debug("flush buffer age")
+
+	if err := a.flushAndReopen(); err != nil {
+		a.staleBackoff *= 2
+		if a.staleBackoff == 0 {
+			a.staleBackoff = a.FlushBackoff
+		}
+
+		if a.staleBackoff > a.MaxBufferAge {
+			a.staleBackoff = a.MaxBufferAge
+		}
+
+		a.staleRetry = now.Add(a.staleBackoff)
+		a.Log.WithError(err).WithField("retry", a.staleRetry).Debug("error flushing buffer age")
+		return
+	}
+
+	a.staleBackoff = 0
+	a.staleRetry = time.Time{}
+}
+
+// trim drops the oldest events beyond MaxEvents.
+func (a *Analytics) trim() error {
+	n, err := a.size()
+	if err != nil {
+		return err
+	}
+
+	if n <= a.MaxEvents {
+		return nil
+	}
+
+	events, err := a.readEvents()
+	if err != nil {
+		return errors.Wrap(err, "reading events")
+	}
+
+	over := len(events) - a.MaxEvents
+
+	for _, e := range events[:over] {
+		a.drop(e, ErrBufferFull)
+	}
+
+	a.Log.WithField("discarded", over).Debug("buffer full")
+	return a.rewrite(events[over:])
+}
+
+// previousEvent returns the name of the last tracked event, read
+// from ~/<dir>/last_event when necessary.
+func (a *Analytics) previousEvent() string {
+	if a.lastEvent == "" {
+		b, _ := ioutil.ReadFile(filepath.Join(a.root, "last_event"))
+		a.lastEvent = string(b)
+	}
+
+	return a.lastEvent
+}
+
+// savePreviousEvent saves `name` to ~/<dir>/last_event.
+func (a *Analytics) savePreviousEvent(name string) {
+	a.lastEvent = name
+
+	err := ioutil.WriteFile(filepath.Join(a.root, "last_event"), []byte(name), a.FilePerm)
+	if err != nil {
+		a.Log.WithError(err).Debug("error saving last event")
+	}
+}
+
+// sequence returns the next event sequence number, persisted
+// in ~/<dir>/seq so that it continues across runs.
+func (a *Analytics) sequence() (int64, error) {
+	unlock, err := a.lockBuffer()
+	if err != nil {
+		return 0, err
+	}
+
+	defer unlock()
+
+	path := filepath.Join(a.root, "seq")
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	n, _ := strconv.ParseInt(string(b), 10, 64)
+	n++
+
+	if err := ioutil.WriteFile(path, []byte(strconv.FormatInt(n, 10)), a.FilePerm); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// allow returns true if an event may be tracked under MaxTrackRate at the
+// Clock's time, blocking until it may when BlockOnRateLimit is set. The lock
+// is released while waiting, and false is returned when the Clock doesn't
+// advance, as the event would never be allowed.
+func (a *Analytics) allow() bool {
+	if a.limiter == nil {
+		a.limiter = newLimiter(a.MaxTrackRate)
+	}
+
+	for {
+		now := a.Clock.Now()
+
+		ok, wait := a.limiter.allow(now)
+		if ok {
+			return true
+		}
+
+		if !a.BlockOnRateLimit {
+			return false
+		}
+
+		a.mu.Unlock()
+		a.sleep(wait)
+		a.mu.Lock()
+
+		if !a.Clock.Now().After(now) {
+			a.Log.Debug("clock not advancing while rate limited")
+			return false
+		}
+	}
+}
+
+// sleep waits for `d` on the Clock when it's a Sleeper.
+func (a *Analytics) sleep(d time.Duration) {
+	if s, ok := a.Clock.(Sleeper); ok {
+		s.Sleep(d)
+		return
+	}
+
+	time.Sleep(d)
+}
+
+// sleepContext is like sleep, returning ctx.Err() when `ctx` is done first.
+func (a *Analytics) sleepContext(ctx context.Context, d time.Duration) error {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		a.sleep(d)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ValidateTrack runs event `name` and `props` through the same pipeline
+// as Track, returning any error without buffering the event.
+func (a *Analytics) ValidateTrack(name string, props map[string]interface{}) error {
+	a.mu.Lock()
+	defer a.unlock()
+
+	e, err := a.event(name, props)
+	if err != nil {
+		return err
+	}
+
+	if _, err := a.Codec.Encode(e); err != nil {
+		return errors.Wrap(err, "encoding")
+	}
+
+	return nil
+}
+
+// event returns the event to buffer for `name` and `props`. Properties
+// which can't be encoded are dropped by sanitize, so only the name may
+// be invalid.
+func (a *Analytics) event(name string, props map[string]interface{}) (*Event, error) {
+	if name == "" {
+		return nil, ErrEmptyEventName
+	}
+
+	if a.ValidateEventName != nil {
+		if err := a.ValidateEventName(name); err != nil {
+			return nil, errors.Wrapf(err, "invalid event name %q", name)
+		}
+	}
+
+	props, err := a.limitProperties(a.properties(props))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		Event:      name,
+		Properties: props,
+		Timestamp:  a.Clock.Now(),
+	}, nil
+}
+
+// write event `e` to the buffer, syncing according to Durability.
+func (a *Analytics) write(e *Event) error {
+	if e.MessageID == "" {
+		e.MessageID, _ = uuid.GenerateUUID()
+	}
+
+	if e.BufferedAt.IsZero() {
+		e.BufferedAt = a.Clock.Now()
+	}
+
+	if a.SocketPath != "" {
+		err := a.writeSocket(e)
+		if err == nil {
+			return nil
+		}
+
+		a.Log.WithError(err).Debug("socket unavailable, buffering to disk")
+	}
+
+	unlock, err := a.lockBuffer()
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	if a.store != nil {
+		if err := a.store.Append(e); err != nil {
+			return err
+		}
+
+		a.remember(e, 0)
+		return nil
+	}
+
+	if e.Priority == PriorityHigh {
+		return a.writePriority(e)
+	}
+
+	if err := a.reopenMoved(); err != nil {
+		return err
+	}
+
+	if err := a.rotate(); err != nil {
+		return errors.Wrap(err, "rotating")
+	}
+
+	offset := int64(-1)
+
+	if a.Dedup {
+		if info, err := a.eventsFile.Stat(); err == nil {
+			offset = info.Size()
+		}
+	}
+
+	if err := a.events.Encode(e); err != nil {
+		return err
+	}
+
+	a.counted()
+	a.remember(e, offset)
+
+	if a.Durability >= DurabilityOnEveryWrite && a.eventsFile != nil {
+		return syncFile(a.eventsFile)
+	}
+
+	return nil
+}
+
+// counted updates the cached count after writing an event, the
+// cache is left invalid when it was already.
+func (a *Analytics) counted() {
+	if a.countSize < 0 || a.eventsFile == nil {
+		return
+	}
+
+	a.count++
+	a.cacheSize()
+}
+
+// cacheSize records the size of the buffer the cached count is valid for.
+func (a *Analytics) cacheSize() {
+	total, err := a.bufferSize()
+	if err != nil {
+		a.countSize = -1
+		return
+	}
+
+	a.countSize = total
+}
+
+// flushAndReopen flushes and then reopens the events file,
+// allowing tracking to continue after the flush.
+func (a *Analytics) flushAndReopen() error {
+	err := a.flush(context.Background(), false)
+
+	if err == ErrFlushPaused || err == ErrOffline || err == ErrFlushThrottled {
+		return nil
+	}
+
+	a.initEvents()
+	return err
+}
+
+// oldest returns the timestamp of the first buffered event,
+// or the zero time when the buffer is empty.
+func (a *Analytics) oldest() (time.Time, error) {
+	if a.store != nil {
+		events, err := a.store.ReadAll()
+		if err != nil || len(events) == 0 {
+			return time.Time{}, err
+		}
+
+		return events[0].Timestamp, nil
+	}
+
+	r, err := a.reader()
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "opening")
+	}
+
+	defer r.Close()
+
+	var first time.Time
+
+	err = a.decodeEach(r, func(e *Event) error {
+		first = e.Timestamp
+		return io.EOF
+	})
+
+	if err != nil && err != io.EOF {
+		return time.Time{}, errors.Wrap(err, "decoding")
+	}
+
+	return first, nil
+}
+
+// flushOnEvent returns true if event `name` should trigger a flush.
+func (a *Analytics) flushOnEvent(name string) bool {
+	return contains(a.FlushOnEvents, name)
+}
+
+// disabledEvent returns true if event `name` is disabled.
+func (a *Analytics) disabledEvent(name string) bool {
+	return contains(a.DisabledEvents, name)
+}
+
+// contains returns true if `s` is in `list`.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// properties returns the properties stored for an event, a
+// sanitized copy of `props` plus any injected properties.
+func (a *Analytics) properties(props map[string]interface{}) map[string]interface{} {
+	props = a.merge(props)
+	props = a.sanitize(props)
+	props = a.normalizeKeys(props, a.PropertyKeyCase)
+
+	if a.DetectTTY {
+		props = set(props, "interactive", isTerminal())
+	}
+
+	if a.workspace != "" {
+		props = set(props, "workspace_id", a.workspace)
+	}
+
+	if a.InstallSource != "" {
+		props = set(props, "install_source", a.InstallSource)
+	}
+
+	if a.Redact != nil {
+		props = a.Redact(props)
+	}
+
+	return props
+}
+
+// merge returns the global properties overridden by `props`.
+func (a *Analytics) merge(props map[string]interface{}) map[string]interface{} {
+	if len(a.globals) == 0 {
+		return props
+	}
+
+	v := make(map[string]interface{})
+
+	for k, val := range a.globals {
+		v[k] = val
+	}
+
+	for k, val := range props {
+		v[k] = val
+	}
+
+	return v
+}
+
+// InstallSourceFromEnv returns the install source such as "homebrew"
+// or "curl" recorded by an installer in the environment variable `name`,
+// or "unknown" when unset.
+func InstallSourceFromEnv(name string) string {
+	if s := os.Getenv(name); s != "" {
+		return s
+	}
+
+	return "unknown"
+}
+
+// set property `k` to `v`, allocating `props` when nil.
+func set(props map[string]interface{}, k string, v interface{}) map[string]interface{} {
+	if props == nil {
+		props = make(map[string]interface{})
+	}
+
+	props[k] = v
+	return props
+}
+
+// syncFile commits `f` to disk.
+var syncFile = func(f *os.File) error {
+	return f.Sync()
+}
+
+// isTerminal returns true if stdout is a terminal.
+var isTerminal = func() bool {
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// sanitize returns a copy of `props` without values which can't be
+// encoded as JSON, so that a single bad property doesn't fail Track.
+func (a *Analytics) sanitize(props map[string]interface{}) map[string]interface{} {
+	if props == nil {
+		return nil
+	}
+
+	v := make(map[string]interface{}, len(props))
+
+	for k, val := range props {
+		if _, err := json.Marshal(val); err != nil {
+			a.Log.WithError(err).WithField("property", k).Warn("dropping unencodable property")
+			continue
+		}
+
+		v[k] = val
+	}
+
+	return v
+}
+
+// Anonymize removes the AnonymizeKeys properties from buffered events older
+// than `olderThan`, keeping the event name and timestamp. Events without a
+// timestamp are of unknown age and are treated as old.
+func (a *Analytics) Anonymize(olderThan time.Duration) error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	events, err := a.readEvents()
+	if err != nil {
+		return errors.Wrap(err, "reading events")
+	}
+
+	cutoff := a.Clock.Now().Add(-olderThan)
+
+	for _, e := range events {
+		if e.Timestamp.After(cutoff) {
+			continue
+		}
+
+		for _, k := range a.AnonymizeKeys {
+			delete(e.Properties, k)
+		}
+	}
+
+	return a.rewrite(events)
+}
+
+// FlushFunc delivers only the buffered events for which `match` returns true,
+// such as crashes which should be reported immediately, returning the number
+// delivered. Delivered events are then removed by atomically rewriting the
+// buffer, so the remaining events are kept for the next flush.
+func (a *Analytics) FlushFunc(match func(*Event) bool) (int, error) {
+	if a.noop {
+		return 0, nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	if a.paused {
+		return 0, ErrFlushPaused
+	}
+
+	if a.disabled() {
+		return 0, nil
+	}
+
+	if a.offline() {
+		return 0, ErrOffline
+	}
+
+	events, err := a.readEvents()
+	if err != nil {
+		return 0, storageError(errors.Wrap(err, "reading events"))
+	}
+
+	var send []*Event
+
+	for _, e := range events {
+		if match(e) {
+			send = append(send, e)
+		}
+	}
+
+	if len(send) == 0 {
+		return 0, nil
+	}
+
+	err = a.sendRetry(context.Background(), send)
+
+	kept, partial := undelivered(err)
+	if err != nil && !partial {
+		return 0, deliveryError(err)
+	}
+
+	sent := make(map[*Event]bool, len(send))
+	for _, e := range send {
+		sent[e] = true
+	}
+
+	for _, e := range kept {
+		delete(sent, e)
+	}
+
+	var keep []*Event
+
+	for _, e := range events {
+		if !sent[e] {
+			keep = append(keep, e)
+		}
+	}
+
+	a.metrics.Flushed += len(sent)
+
+	if err := a.rewrite(keep); err != nil {
+		return len(sent), storageError(errors.Wrap(err, "rewriting"))
+	}
+
+	return len(sent), err
+}
+
+// DeleteEvents removes the buffered events for which `match` returns true,
+// returning the number removed. The buffer is rewritten atomically.
+func (a *Analytics) DeleteEvents(match func(*Event) bool) (int, error) {
+	if a.noop {
+		return 0, nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	events, err := a.readEvents()
+	if err != nil {
+		return 0, errors.Wrap(err, "reading events")
+	}
+
+	var keep []*Event
+
+	for _, e := range events {
+		if !match(e) {
+			keep = append(keep, e)
+		}
+	}
+
+	n := len(events) - len(keep)
+	if n == 0 {
+		return 0, nil
+	}
+
+	if err := a.rewrite(keep); err != nil {
+		return 0, errors.Wrap(err, "rewriting")
+	}
+
+	return n, nil
+}
+
+// Compact rewrites the buffer without the events which Flush would drop,
+// those buffered longer than MaxRetryAge or older than EventTTL, collapsing identical consecutive events
+// when Dedup is enabled. The remaining events keep their order, and the
+// buffer is locked against other processes while it's rewritten.
+func (a *Analytics) Compact() error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	if a.disabled() {
+		return nil
+	}
+
+	unlock, err := a.lockBuffer()
+	if err != nil {
+		return storageError(err)
+	}
+
+	defer unlock()
+
+	events, err := a.readEvents()
+	if err != nil {
+		return storageError(errors.Wrap(err, "reading events"))
+	}
+
+	n := len(events)
+
+	if a.MaxRetryAge > 0 || a.EventTTL > 0 {
+		events = a.dropExpired(events)
+	}
+
+	if a.Dedup {
+		events = a.collapse(events)
+	}
+
+	if len(events) == n {
+		return nil
+	}
+
+	if err := a.rewriteLocked(events); err != nil {
+		return storageError(errors.Wrap(err, "rewriting"))
+	}
+
+	return nil
+}
+
+// rewrite atomically replaces the events on disk with `events`,
+// reopening the events file when open.
+func (a *Analytics) rewrite(events []*Event) error {
+	unlock, err := a.lockBuffer()
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+	return a.rewriteLocked(events)
+}
+
+// rewriteLocked is rewrite with the buffer already locked.
+func (a *Analytics) rewriteLocked(events []*Event) error {
+	if a.eventsFile != nil && a.store == nil {
+		a.closeEvents()
+		defer a.initEvents()
+	}
+
+	return a.replace(events)
+}
+
+// replace atomically replaces the events on disk with `events`.
+func (a *Analytics) replace(events []*Event) error {
+	if s, ok := a.store.(*stateStore); ok {
+		return s.replace(events)
+	}
+
+	if a.store != nil {
+		if err := a.store.Reset(); err != nil {
+			return errors.Wrap(err, "resetting")
+		}
+
+		for _, e := range events {
+			if err := a.store.Append(e); err != nil {
+				return errors.Wrap(err, "appending")
+			}
+		}
+
+		return nil
+	}
+
+	rotated, err := a.rotated()
+	if err != nil {
+		return errors.Wrap(err, "listing rotated events")
+	}
+
+	if err := a.replaceFile(filepath.Join(a.root, "events"), events); err != nil {
+		return err
+	}
+
+	return removeRotated(rotated)
+}
+
+// replaceFile atomically replaces the events of file `path` with `events`.
+func (a *Analytics) replaceFile(path string, events []*Event) error {
+	a.countSize = -1
+	a.last = nil
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, a.FilePerm)
+	if err != nil {
+		return errors.Wrap(err, "creating")
+	}
+
+	enc := a.encoder(f)
+
+	if a.EventsHeader {
+		if err := a.writeHeader(f, enc); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return errors.Wrap(err, "writing header")
+		}
+	}
+
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return errors.Wrap(err, "encoding")
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return errors.Wrap(err, "closing")
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// PauseFlush makes Flush and ConditionalFlush no-ops returning ErrFlushPaused
+// until ResumeFlush is called, while events continue to be buffered.
+func (a *Analytics) PauseFlush() {
+	a.mu.Lock()
+	defer a.unlock()
+	a.Log.Debug("pause flush")
+	a.paused = true
+}
+
+// ResumeFlush resumes flushing after PauseFlush.
+func (a *Analytics) ResumeFlush() {
+	a.mu.Lock()
+	defer a.unlock()
+	a.Log.Debug("resume flush")
+	a.paused = false
+}
+
+// TimeToNextFlush returns how long until ConditionalFlush with the same
+// arguments would flush due to age, or zero when it would flush now.
+func (a *Analytics) TimeToNextFlush(aboveSize int, aboveDuration time.Duration) (time.Duration, error) {
+	if a.noop {
+		return 0, nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	age, err := a.flushAge()
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := a.size()
+	if err != nil {
+		return 0, err
+	}
+
+	if size >= aboveSize || age >= aboveDuration {
+		return 0, nil
+	}
+
+	return aboveDuration - age, nil
+}
+
+// ConditionalFlush flushes if event count is above `aboveSize`, or age is `aboveDuration`,
+// otherwise Close() is called and the underlying file(s) are closed. When tracking is
+// disabled only Close() is called.
+func (a *Analytics) ConditionalFlush(aboveSize int, aboveDuration time.Duration) error {
+	a.mu.Lock()
+	defer a.unlock()
+	return a.conditionalFlush(aboveSize, 0, aboveDuration)
+}
+
+// ConditionalFlushBytes is like ConditionalFlush, also flushing when the
+// events on disk take up at least `aboveBytes`, bounding the buffer when
+// event sizes vary widely.
+func (a *Analytics) ConditionalFlushBytes(aboveSize int, aboveBytes int64, aboveDuration time.Duration) error {
+	a.mu.Lock()
+	defer a.unlock()
+	return a.conditionalFlush(aboveSize, aboveBytes, aboveDuration)
+}
+
+// conditionalFlush implementation, a zero `aboveBytes` is ignored.
+func (a *Analytics) conditionalFlush(aboveSize int, aboveBytes int64, aboveDuration time.Duration) error {
+	if a.paused {
+		return ErrFlushPaused
+	}
+
+	if a.disabled() {
+		return a.close()
+	}
+
+	if a.offline() {
+		return ErrOffline
+	}
+
+	if err := a.flushPriority(); err != nil {
+		return errors.Wrap(err, "flushing priority events")
+	}
+
+	age, err := a.flushAge()
+	if err != nil {
+		return err
+	}
+
+	size, err := a.size()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+
+	if aboveBytes > 0 && a.store == nil {
+		total, err = a.bufferSize()
+		if err != nil {
+			return err
+		}
+	}
+
+	aboveDuration = a.jittered(aboveDuration)
+
+	ctx := a.Log.WithFields(log.Fields{
+		"age":            age,
+		"size":           size,
+		"bytes":          total,
+		"above_size":     aboveSize,
+		"above_bytes":    aboveBytes,
+		"above_duration": aboveDuration,
+	})
+
+	switch {
+	case size >= aboveSize:
+		ctx.Debug("flush size")
+		return a.flush(context.Background(), false)
+	case aboveBytes > 0 && total >= aboveBytes:
+		ctx.Debug("flush bytes")
+		return a.flush(context.Background(), false)
+	case age >= aboveDuration:
 		ctx.Debug("flush age")
-		return a.Flush()
+		return a.flush(context.Background(), false)
+	case a.FlushEveryNRuns > 0 && a.runs >= a.FlushEveryNRuns:
+		ctx.WithField("runs", a.runs).Debug("flush runs")
+		return a.flush(context.Background(), false)
 	default:
-		return a.Close()
+		return a.close()
+	}
+}
+
+// Flush the events to Segment, removing them from disk only once delivered.
+// The attempt is recorded by Touch(), and success in ~/<dir>/last_flush_success.
+// When MinEventsToFlush is set and not yet reached the events are kept and only
+// Close() is called. Flush is a no-op when tracking is disabled, and returns
+// ErrFlushThrottled within MinFlushInterval of the last flush.
+func (a *Analytics) Flush() error {
+	return a.FlushContext(context.Background())
+}
+
+// FlushContext is like Flush, abandoning delivery and returning ctx.Err()
+// when `ctx` is done. The events of a cancelled flush are kept.
+func (a *Analytics) FlushContext(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.unlock()
+	return a.flush(ctx, false)
+}
+
+// FlushTimeout is like Flush, returning ErrFlushTimeout when the flush
+// takes longer than `d`, such as on a bad network. The events are kept for
+// the next flush, and the abandoned flush finishes in the background
+// without removing them.
+func (a *Analytics) FlushTimeout(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	done := make(chan error, 1)
+
+	go func() {
+		defer cancel()
+		done <- a.FlushContext(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return flushTimeoutError(err)
+	case <-ctx.Done():
+		select {
+		case err := <-done:
+			return flushTimeoutError(err)
+		default:
+			return ErrFlushTimeout
+		}
+	}
+}
+
+// flushTimeoutError returns ErrFlushTimeout for a flush which exceeded its deadline.
+func flushTimeoutError(err error) error {
+	if err == context.DeadlineExceeded {
+		return ErrFlushTimeout
+	}
+
+	return err
+}
+
+// FlushN is like Flush, returning the number of events delivered.
+func (a *Analytics) FlushN() (int, error) {
+	a.mu.Lock()
+	defer a.unlock()
+
+	n := a.metrics.Flushed
+	err := a.flush(context.Background(), false)
+	return a.metrics.Flushed - n, err
+}
+
+// FlushPartial is like Flush, returning the number of events delivered and
+// the events which were rejected, which remain buffered while the rest are
+// removed. Rejections are reported by DirectHTTP and by a Transport returning
+// a *RejectedError, the Segment client delivers all or nothing. When the
+// flush fails, entirely or after some batches were delivered, the error
+// is returned and the undelivered events are kept.
+func (a *Analytics) FlushPartial() (int, []*Event, error) {
+	a.mu.Lock()
+	defer a.unlock()
+
+	n := a.metrics.Flushed
+	err := a.flush(context.Background(), false)
+	delivered := a.metrics.Flushed - n
+
+	if rerr, ok := err.(*RejectedError); ok {
+		return delivered, tracked(rerr.Events), nil
+	}
+
+	return delivered, nil, err
+}
+
+// FlushTo is like FlushN, handing the events to `fn` in place of Segment
+// or the Transport, such as to ship them to another system. The events
+// are removed only once `fn` succeeds, and kept when it returns an error.
+func (a *Analytics) FlushTo(fn func(userID string, events []*Event) error) (int, error) {
+	a.mu.Lock()
+	defer a.unlock()
+	a.waitFlush()
+
+	transport := a.Transport
+	a.Transport = TransportFunc(fn)
+	defer func() { a.Transport = transport }()
+
+	n := a.metrics.Flushed
+	err := a.flush(context.Background(), false)
+	return a.metrics.Flushed - n, err
+}
+
+// ForceFlush flushes the events to Segment regardless of MinEventsToFlush.
+func (a *Analytics) ForceFlush() error {
+	a.mu.Lock()
+	defer a.unlock()
+	return a.flush(context.Background(), true)
+}
+
+// offline returns true when OfflineCheck reports being offline.
+func (a *Analytics) offline() bool {
+	return a.OfflineCheck != nil && a.OfflineCheck()
+}
+
+// flush implementation.
+func (a *Analytics) flush(ctx context.Context, force bool) error {
+	if a.err != nil {
+		return a.err
+	}
+
+	if a.paused {
+		return ErrFlushPaused
+	}
+
+	if a.disabled() {
+		return nil
+	}
+
+	if a.offline() {
+		a.Log.Debug("offline, skipping flush")
+		return ErrOffline
+	}
+
+	if !force && a.throttled() {
+		a.Log.Debug("flushed recently, skipping flush")
+		return ErrFlushThrottled
+	}
+
+	parent := ctx
+
+	if a.MaxFlushDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.MaxFlushDuration)
+		defer cancel()
+	}
+
+	n := a.metrics.Flushed
+	err := storageError(a.flushEvents(ctx, force))
+
+	if err == context.DeadlineExceeded && parent.Err() == nil {
+		err = ErrFlushTimeout
+	}
+
+	if err != nil {
+		a.metrics.FlushFailures++
+		a.emit(StateError, err)
+	} else {
+		a.emit(StateFlushed, nil)
+	}
+
+	if a.OnFlush != nil {
+		count := a.metrics.Flushed - n
+		a.later(func() { a.OnFlush(count, err) })
+	}
+
+	return err
+}
+
+// throttled returns true when the last flush was within MinFlushInterval.
+// The first run counts as a flush, as New() records it with Touch(), while
+// a last flush in the future due to clock skew doesn't.
+func (a *Analytics) throttled() bool {
+	if a.MinFlushInterval <= 0 {
+		return false
+	}
+
+	lastFlush, err := a.LastFlush()
+	if err != nil {
+		return false
+	}
+
+	age := a.Clock.Now().Sub(lastFlush)
+	return age >= 0 && age < a.MinFlushInterval
+}
+
+// flushEvents implementation.
+func (a *Analytics) flushEvents(ctx context.Context, force bool) (err error) {
+	a.waitFlush()
+	a.countSize = -1
+
+	if a.Durability >= DurabilityOnFlush && a.eventsFile != nil {
+		if err := syncFile(a.eventsFile); err != nil {
+			return errors.Wrap(err, "syncing")
+		}
+	}
+
+	if err := a.closeEvents(); err != nil {
+		return errors.Wrap(err, "closing")
+	}
+
+	ok, unlockFlush, err := a.tryLockFlush()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		a.Log.Debug("flush in progress by another process")
+		return nil
+	}
+
+	defer unlockFlush()
+
+	snapshot := a.store == nil && a.FlushClearMode == ClearRemove
+
+	// events are truncated in place, so the buffer is locked for the
+	// duration of the flush, whereas the delivered events are removed
+	// from the state in a single write
+	if !snapshot && a.store == nil {
+		unlock, err := a.lockBuffer()
+		if err != nil {
+			return err
+		}
+
+		defer unlock()
+	}
+
+	rotated, err := a.rotated()
+	if err != nil {
+		return errors.Wrap(err, "listing rotated events")
+	}
+
+	events, err := a.readSnapshot(snapshot)
+	if err != nil {
+		return errors.Wrap(err, "reading events")
+	}
+
+	buffered := events
+
+	// the events file is read along with the rotated
+	// files unless it has been snapshotted
+	if snapshot && len(rotated) > 0 {
+		older, err := a.readRotated(rotated)
+		if err != nil {
+			return errors.Wrap(err, "reading rotated events")
+		}
+
+		events = append(older, events...)
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		if err := a.restore(snapshot); err != nil {
+			a.Log.WithError(err).Error("restoring events")
+		}
+	}()
+
+	high, err := a.readPriority(snapshot)
+	if err != nil {
+		return errors.Wrap(err, "reading priority events")
+	}
+
+	events = append(high, events...)
+
+	if !force && len(events) < a.MinEventsToFlush {
+		a.Log.WithField("size", len(events)).Debug("below minimum flush size")
+		return a.restore(snapshot)
+	}
+
+	if a.MaxRetryAge > 0 || a.EventTTL > 0 {
+		events = a.dropExpired(events)
+	}
+
+	if a.SummaryEvent != "" {
+		events = a.summarize(events)
+	}
+
+	events = latestGroups(events)
+
+	if a.store == nil || a.SingleFile {
+		if err := a.Touch(); err != nil {
+			return errors.Wrap(err, "touching")
+		}
+	}
+
+	id, err := a.identify()
+	if err != nil {
+		return errors.Wrap(err, "reading traits")
+	}
+
+	if id != nil {
+		events = append([]*Event{id}, events...)
+	}
+
+	var originals map[*Event]*Event
+
+	if a.BeforeSend != nil {
+		events, originals = a.beforeSend(events)
+	}
+
+	err = a.deliver(ctx, events, snapshot)
+
+	// events delivered after the flush was abandoned are kept,
+	// their message ids let Segment dedupe them when resent
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	if kept, ok := undelivered(err); ok {
+		a.Log.WithField("undelivered", len(kept)).Debug("events undelivered")
+		a.metrics.Flushed += len(tracked(events)) - len(tracked(kept))
+
+		if originals != nil {
+			kept = original(kept, originals)
+
+			switch e := err.(type) {
+			case *RejectedError:
+				e.Events = kept
+			case *PartialError:
+				e.Events = kept
+			}
+		}
+
+		if err := a.replaceFlushed(snapshot, buffered, tracked(kept)); err != nil {
+			return errors.Wrap(err, "keeping undelivered events")
+		}
+
+		if err := removeRotated(rotated); err != nil {
+			return errors.Wrap(err, "removing rotated events")
+		}
+
+		if err := a.clearPriority(snapshot); err != nil {
+			return errors.Wrap(err, "clearing priority events")
+		}
+
+		return err
+	}
+
+	if err != nil {
+		return deliveryError(err)
+	}
+
+	if a.DryRun && a.DryRunKeep {
+		return a.restore(snapshot)
+	}
+
+	a.metrics.Flushed += len(tracked(events))
+	a.resetRuns()
+
+	if err := a.clearPriority(snapshot); err != nil {
+		return errors.Wrap(err, "clearing priority events")
+	}
+
+	if a.store != nil {
+		if err := a.clearStore(buffered); err != nil || !a.SingleFile {
+			return err
+		}
+
+		return a.touch("last_flush_success")
+	}
+
+	if err := a.touch("last_flush_success"); err != nil {
+		return errors.Wrap(err, "touching")
+	}
+
+	if err := removeRotated(rotated); err != nil {
+		return errors.Wrap(err, "removing rotated events")
+	}
+
+	return a.clear()
+}
+
+// clear the flushed events according to FlushClearMode.
+func (a *Analytics) clear() error {
+	path := filepath.Join(a.root, "events")
+
+	if a.FlushClearMode == ClearTruncate {
+		return os.Truncate(path, 0)
+	}
+
+	err := os.Remove(path + ".flushing")
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// readSnapshot returns the events to flush. When `snapshot` is true the
+// events file is first moved to ~/<dir>/events.flushing, so that events
+// tracked during the flush are written to a fresh events file and the
+// snapshot is only removed once delivered. Events left in events.flushing
+// by an interrupted flush are flushed along with the events file.
+func (a *Analytics) readSnapshot(snapshot bool) ([]*Event, error) {
+	if !snapshot {
+		return a.readEvents()
+	}
+
+	return a.snapshotFile(filepath.Join(a.root, "events"))
+}
+
+// snapshotFile moves `path` to <path>.flushing, appending it to the
+// snapshot left by an interrupted flush, and returns its events.
+func (a *Analytics) snapshotFile(path string) ([]*Event, error) {
+	flushing := path + ".flushing"
+
+	unlock, err := a.lockBuffer()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = os.Stat(flushing)
+
+	if os.IsNotExist(err) {
+		err = os.Rename(path, flushing)
+	} else {
+		err = appendFile(flushing, path, a.FilePerm)
+	}
+
+	unlock()
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "snapshotting")
+	}
+
+	f, err := os.Open(flushing)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening")
+	}
+
+	defer f.Close()
+	return a.decodeAll(f)
+}
+
+// restore moves the snapshots of the events and high priority events back
+// when `snapshot` is true, followed by any events tracked during the flush.
+func (a *Analytics) restore(snapshot bool) error {
+	if !snapshot {
+		return nil
 	}
+
+	if err := a.restoreFile(filepath.Join(a.root, "events")); err != nil {
+		return err
+	}
+
+	return a.restoreFile(a.priorityPath())
 }
 
-// Flush the events to Segment, removing them from disk.
-func (a *Analytics) Flush() error {
-	if err := a.Close(); err != nil {
-		return errors.Wrap(err, "closing")
+// restoreFile moves <path>.flushing back to `path`, followed
+// by the events written to `path` since the snapshot.
+func (a *Analytics) restoreFile(path string) error {
+	flushing := path + ".flushing"
+
+	unlock, err := a.lockBuffer()
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	if err := appendFile(flushing, path, a.FilePerm); err != nil {
+		return errors.Wrap(err, "appending")
+	}
+
+	err = os.Rename(flushing, path)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// replaceFlushed replaces the `flushed` events with `events`, keeping
+// any events tracked during the flush.
+func (a *Analytics) replaceFlushed(snapshot bool, flushed, events []*Event) error {
+	if s, ok := a.store.(*stateStore); ok {
+		return s.settle(flushed, events)
+	}
+
+	if !snapshot {
+		return a.replace(events)
+	}
+
+	return a.replaceFile(filepath.Join(a.root, "events.flushing"), events)
+}
+
+// appendFile appends the contents of `src` to `dst`, removing `src`.
+// A missing `src` is ignored.
+func appendFile(dst, src string, perm os.FileMode) error {
+	b, err := ioutil.ReadFile(src)
+
+	if os.IsNotExist(err) {
+		return nil
 	}
 
-	events, err := a.Events()
 	if err != nil {
-		return errors.Wrap(err, "reading events")
+		return err
+	}
+
+	f, err := os.OpenFile(dst, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// sendRetry sends `events`, retrying failed deliveries up to FlushRetries
+// times with exponential backoff. Rejected events and client error
+// responses, such as for an invalid WriteKey, are not retried, and only
+// the undelivered events of a *PartialError are.
+func (a *Analytics) sendRetry(ctx context.Context, events []*Event) error {
+	backoff := a.FlushBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := a.sendGated(ctx, events)
+
+		if perr, ok := err.(*PartialError); ok {
+			events = perr.Events
+		}
+
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err == nil || attempt > a.FlushRetries || !retryable(err) {
+			return err
+		}
+
+		a.Log.WithError(err).WithFields(log.Fields{
+			"attempt": attempt,
+			"backoff": backoff,
+		}).Debug("retrying flush")
+
+		if err := a.sleepContext(ctx, backoff); err != nil {
+			return err
+		}
+
+		backoff *= 2
+	}
+}
+
+// deliver sends `events` with sendRetry. When `unlocked` is true the lock is
+// released for the duration, so that the network and FlushRetries backoff
+// don't block tracking, which is only safe once the events have been moved
+// to a snapshot. Flushes and changes to the state read by delivery wait for
+// it with waitFlush.
+func (a *Analytics) deliver(ctx context.Context, events []*Event, unlocked bool) error {
+	if !unlocked {
+		return a.sendRetry(ctx, events)
+	}
+
+	if a.DirectHTTP {
+		events = a.dropOversized(events)
+	}
+
+	// events tracked during delivery are buffered to a new events
+	// file, which is closed again afterwards as the flush closed it
+	closed := a.events != nil && a.eventsFile == nil
+	size := int64(-1)
+
+	if closed {
+		var err error
+		if size, err = a.openDelivery(); err != nil {
+			return errors.Wrap(err, "opening events")
+		}
+	}
+
+	done := a.flushCond()
+	a.flushing = true
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		a.flushing = false
+		done.Broadcast()
+
+		if closed {
+			a.closeOpened(size)
+		}
+	}()
+
+	return a.sendRetry(ctx, events)
+}
+
+// openDelivery opens the events file for tracking during delivery,
+// returning its size when created by this call, otherwise -1.
+func (a *Analytics) openDelivery() (int64, error) {
+	unlock, err := a.lockBuffer()
+	if err != nil {
+		return -1, err
+	}
+
+	defer unlock()
+
+	_, err = os.Stat(filepath.Join(a.root, "events"))
+	created := os.IsNotExist(err)
+
+	if err := a.openEvents(); err != nil {
+		return -1, err
+	}
+
+	info, err := a.eventsFile.Stat()
+	if err != nil || !created {
+		return -1, nil
+	}
+
+	return info.Size(), nil
+}
+
+// closeOpened closes the events file opened for delivery, removing it
+// when created by openDelivery and still `size` bytes, as nothing was
+// tracked, so that the flush leaves no file behind.
+func (a *Analytics) closeOpened(size int64) {
+	f := a.eventsFile
+	if f == nil {
+		return
+	}
+
+	unlock, err := a.lockBuffer()
+	if err != nil {
+		a.closeEvents()
+		return
+	}
+
+	defer unlock()
+
+	path := filepath.Join(a.root, "events")
+	info, err := f.Stat()
+	a.closeEvents()
+
+	if err != nil || size < 0 || info.Size() != size {
+		return
+	}
+
+	if cur, err := os.Stat(path); err == nil && os.SameFile(info, cur) {
+		os.Remove(path)
+	}
+}
+
+// waitFlush waits for a delivery in progress without the lock.
+func (a *Analytics) waitFlush() {
+	for a.flushing {
+		a.flushCond().Wait()
+	}
+}
+
+// flushCond returns the condition signalled when a delivery completes.
+func (a *Analytics) flushCond() *sync.Cond {
+	if a.flushDone == nil {
+		a.flushDone = sync.NewCond(&a.mu)
+	}
+
+	return a.flushDone
+}
+
+// sendGated sends `events` while holding the FlushGate, when set.
+func (a *Analytics) sendGated(ctx context.Context, events []*Event) error {
+	if a.FlushGate == nil {
+		return a.send(ctx, events)
+	}
+
+	if err := a.FlushGate.Acquire(ctx); err != nil {
+		return errors.Wrap(err, "acquiring flush gate")
+	}
+
+	defer a.FlushGate.Release()
+	return a.send(ctx, events)
+}
+
+// send `events` to Segment, or to the Transport or Output when set. Panics
+// are recovered and returned as errors so that the events are kept.
+func (a *Analytics) send(ctx context.Context, events []*Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.Log.WithField("panic", r).Error("send panic")
+			err = errors.Errorf("send panic: %v", r)
+		}
+	}()
+
+	if a.DryRun {
+		a.logEvents(events)
+		return nil
+	}
+
+	if a.Transport != nil {
+		return a.sendTransport(events)
+	}
+
+	if a.Output != nil {
+		return a.writeLines(events)
+	}
+
+	if a.DirectHTTP {
+		return a.sendHTTP(ctx, events)
+	}
+
+	return a.sendSegment(ctx, events)
+}
+
+// logEvents logs `events` in place of sending them.
+func (a *Analytics) logEvents(events []*Event) {
+	for _, e := range events {
+		m := a.message(e)
+
+		a.Log.WithFields(log.Fields{
+			"type":         m.Type,
+			"event":        m.Event,
+			"properties":   m.Properties,
+			"user_id":      m.UserID,
+			"anonymous_id": m.AnonymousID,
+		}).Info("dry run")
+	}
+}
+
+// sendTransport delivers `events` using the Transport.
+func (a *Analytics) sendTransport(events []*Event) error {
+	id := a.userID
+	if id == "" {
+		id = a.anonymousID
+	}
+
+	if err := a.Transport.Send(id, events); err != nil {
+		a.Transport.Close()
+
+		if _, ok := undelivered(err); ok {
+			return err
+		}
+
+		return errors.Wrap(err, "sending")
+	}
+
+	if err := a.Transport.Close(); err != nil {
+		return errors.Wrap(err, "closing transport")
 	}
 
+	return nil
+}
+
+// sendSegment delivers `events` using the Segment client. Panics from the
+// client are recovered and returned as errors so that the events are kept.
+func (a *Analytics) sendSegment(ctx context.Context, events []*Event) (err error) {
+	var current *Event
+
+	defer func() {
+		if r := recover(); r != nil {
+			a.Log.WithField("event", current).Error("segment client panic")
+			err = errors.Errorf("segment client panic: %v", r)
+		}
+	}()
+
 	client := segment.New(a.WriteKey)
 	client.Logger = stdlog.New(ioutil.Discard, "", 0)
 
+	if a.Endpoint != "" {
+		client.Endpoint = a.Endpoint
+	}
+
+	if a.BatchSize > 0 {
+		client.Size = a.BatchSize
+	}
+
+	if a.FlushInterval > 0 {
+		client.Interval = a.FlushInterval
+	}
+
+	// the client only logs delivery errors, so they're
+	// captured from the transport and returned after Close()
+	var sendErr error
+
+	hc := a.httpClient()
+	hc.Transport = &observer{
+		transport: &contextTransport{
+			transport: hc.Transport,
+			ctx:       ctx,
+		},
+		fn: func(_ *http.Request, res *http.Response, err error) {
+			switch {
+			case sendErr != nil:
+			case err != nil:
+				sendErr = errors.Wrap(err, "requesting")
+			case res.StatusCode >= 300:
+				sendErr = &StatusError{Code: res.StatusCode, Status: res.Status}
+			}
+		},
+	}
+
+	client.Client = *hc
+
 	for _, event := range events {
-		client.Track(&segment.Track{
-			Event:      event.Event,
-			UserId:     a.userID,
-			Properties: event.Properties,
-		})
+		current = event
+
+		msg := segment.Message{
+			MessageId: event.MessageID,
+			Timestamp: timestamp(event.Timestamp),
+		}
+
+		switch event.Type {
+		case TypeAlias:
+			client.Alias(&segment.Alias{
+				PreviousId: event.PreviousID,
+				UserId:     event.UserID,
+				Message:    msg,
+			})
+		case TypeIdentify:
+			client.Identify(&segment.Identify{
+				UserId:      a.userID,
+				AnonymousId: a.anonymousID,
+				Traits:      event.Traits,
+				Context:     a.context(),
+				Message:     msg,
+			})
+		case TypeGroup:
+			client.Group(&segment.Group{
+				UserId:      a.userID,
+				AnonymousId: a.anonymousID,
+				GroupId:     event.GroupID,
+				Traits:      event.Traits,
+				Context:     a.context(),
+				Message:     msg,
+			})
+		default:
+			t := &segment.Track{
+				Event:        event.Event,
+				UserId:       a.userID,
+				AnonymousId:  a.anonymousID,
+				Properties:   event.Properties,
+				Context:      a.context(),
+				Integrations: a.integrations(event),
+				Message:      msg,
+			}
+
+			if event.Anonymous {
+				t.UserId = ""
+			}
+
+			client.Track(t)
+		}
+	}
+
+	// Close() blocks until the client's queue drains, so it's
+	// abandoned when `ctx` is done, leaving the events buffered
+	closed := make(chan error, 1)
+
+	go func() {
+		closed <- client.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			return errors.Wrap(err, "closing client")
+		}
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 
-	if err := client.Close(); err != nil {
-		return errors.Wrap(err, "closing client")
+	if sendErr != nil {
+		return errors.Wrap(sendErr, "closing client")
 	}
 
-	if err := a.Touch(); err != nil {
-		return errors.Wrap(err, "touching")
+	return nil
+}
+
+// timestamp returns `t` formatted for Segment, or an empty string for
+// the zero time of events buffered without one, so the flush time is used.
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// summarize appends a SummaryEvent to `events` with the count of each event
+// name, the total, and the duration in seconds between the first and last
+// event. When SummaryOnly is set the track events are replaced by the summary.
+func (a *Analytics) summarize(events []*Event) []*Event {
+	var v []*Event
+	var first, last time.Time
+	counts := make(map[string]int)
+	total := 0
+
+	for _, e := range events {
+		if e.Type != "" && e.Type != TypeTrack {
+			v = append(v, e)
+			continue
+		}
+
+		if !a.SummaryOnly {
+			v = append(v, e)
+		}
+
+		counts[e.Event]++
+		total++
+
+		if first.IsZero() || e.Timestamp.Before(first) {
+			first = e.Timestamp
+		}
+
+		if e.Timestamp.After(last) {
+			last = e.Timestamp
+		}
+	}
+
+	if total == 0 {
+		return v
+	}
+
+	return append(v, &Event{
+		Event: a.SummaryEvent,
+		Properties: map[string]interface{}{
+			"counts":   counts,
+			"total":    total,
+			"duration": last.Sub(first).Seconds(),
+		},
+		Timestamp: a.Clock.Now(),
+	})
+}
+
+// resetRuns resets the run count after a flush.
+func (a *Analytics) resetRuns() {
+	if a.runs == 0 {
+		return
+	}
+
+	a.runs = 0
+	os.Remove(filepath.Join(a.root, "runs"))
+}
+
+// dropExpired removes events which have been buffered for longer
+// than MaxRetryAge, so a batch which persistently fails to send is
+// eventually abandoned, and those older than EventTTL, whose context
+// is stale.
+func (a *Analytics) dropExpired(events []*Event) (v []*Event) {
+	for _, e := range events {
+		if a.expired(e) {
+			a.drop(e, ErrRetryAgeExceeded)
+			continue
+		}
+
+		if a.stale(e) {
+			a.drop(e, ErrEventExpired)
+			continue
+		}
+
+		v = append(v, e)
+	}
+
+	return v
+}
+
+// beforeSend returns `events` transformed by BeforeSend, along with the
+// original of each transformed event. BeforeSend is passed copies, so that
+// the buffered events are untouched.
+func (a *Analytics) beforeSend(events []*Event) ([]*Event, map[*Event]*Event) {
+	var v []*Event
+	originals := make(map[*Event]*Event, len(events))
+
+	for _, e := range events {
+		c := *e
+
+		if e.Properties != nil {
+			c.Properties = make(map[string]interface{}, len(e.Properties))
+
+			for k, val := range e.Properties {
+				c.Properties[k] = val
+			}
+		}
+
+		t := a.BeforeSend(&c)
+		if t == nil {
+			a.drop(e, ErrDroppedBeforeSend)
+			continue
+		}
+
+		originals[t] = e
+		v = append(v, t)
+	}
+
+	return v, originals
+}
+
+// original returns the originals of events transformed by BeforeSend.
+func original(events []*Event, originals map[*Event]*Event) (v []*Event) {
+	for _, e := range events {
+		if o, ok := originals[e]; ok {
+			e = o
+		}
+
+		v = append(v, e)
+	}
+
+	return v
+}
+
+// expired returns true if `e` was buffered longer than MaxRetryAge, which
+// is measured from when it was buffered rather than its Timestamp, as events
+// may be backfilled with TrackAt. Events buffered by earlier versions fall
+// back to the Timestamp, and those without either never expire.
+func (a *Analytics) expired(e *Event) bool {
+	if a.MaxRetryAge <= 0 {
+		return false
+	}
+
+	t := e.BufferedAt
+
+	if t.IsZero() {
+		t = e.Timestamp
+	}
+
+	if t.IsZero() {
+		return false
+	}
+
+	return a.Clock.Now().Sub(t) > a.MaxRetryAge
+}
+
+// stale returns true if the Timestamp of `e` is older than EventTTL.
+// Events without a timestamp never expire.
+func (a *Analytics) stale(e *Event) bool {
+	if a.EventTTL <= 0 || e.Timestamp.IsZero() {
+		return false
 	}
 
-	return os.Remove(filepath.Join(a.root, "events"))
+	return a.Clock.Now().Sub(e.Timestamp) > a.EventTTL
+}
+
+// drop event `e` due to `reason`.
+func (a *Analytics) drop(e *Event, reason error) {
+	a.Log.WithError(reason).WithField("event", e.Event).Debug("dropping event")
+	a.metrics.Dropped++
+
+	if a.OnDrop != nil {
+		a.later(func() { a.OnDrop(e, reason) })
+	}
 }
 
 // Close the underlying file descriptor(s).
 func (a *Analytics) Close() error {
-	return a.eventsFile.Close()
+	a.mu.Lock()
+	defer a.unlock()
+	a.waitFlush()
+	return a.close()
+}
+
+// close implementation.
+func (a *Analytics) close() error {
+	a.closeSocket()
+
+	if err := a.closeEvents(); err != nil {
+		return err
+	}
+
+	a.emit(StateClosed, nil)
+	return nil
+}
+
+// closeEvents closes the events file, releasing the handle so that it
+// may be removed or renamed, which fails on Windows while it's open.
+func (a *Analytics) closeEvents() error {
+	if a.eventsFile == nil {
+		return nil
+	}
+
+	f := a.eventsFile
+	a.eventsFile = nil
+	return f.Close()
+}
+
+// emit lifecycle `state` to the Lifecycle channel without blocking,
+// the event is dropped when the channel is full.
+func (a *Analytics) emit(state string, err error) {
+	if a.Lifecycle == nil {
+		return
+	}
+
+	select {
+	case a.Lifecycle <- LifecycleEvent{State: state, Err: err}:
+	default:
+	}
 }
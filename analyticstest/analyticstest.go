@@ -0,0 +1,70 @@
+// Package analyticstest provides a fake Segment API for integration testing
+// programs which use the analytics package.
+//
+//	s := analyticstest.NewServer()
+//	defer s.Close()
+//
+//	a := analytics.New(&analytics.Config{
+//	  WriteKey: "test",
+//	  Dir:      ".myapp",
+//	  Endpoint: s.URL,
+//	})
+//
+//	a.Track("Something", nil)
+//	a.Flush()
+//
+//	s.Messages() // => [{"type": "track", "event": "Something", ...}]
+package analyticstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Message is a captured Segment message.
+type Message map[string]interface{}
+
+// batch is the Segment batch payload.
+type batch struct {
+	Messages []Message `json:"batch"`
+}
+
+// Server is a fake Segment API capturing the messages delivered to it.
+type Server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewServer returns a started fake Segment API. Use its URL as the
+// Endpoint of the tracker, and Close() it when finished.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// handle a batch request.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	var b batch
+
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.messages = append(s.messages, b.Messages...)
+	s.mu.Unlock()
+
+	w.Write([]byte(`{}`))
+}
+
+// Messages returns the messages received so far.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
@@ -0,0 +1,36 @@
+package analyticstest_test
+
+import (
+	"testing"
+
+	"github.com/tj/go-cli-analytics"
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestServer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := analytics.New(&analytics.Config{WriteKey: "test", Dir: ".myapp", Endpoint: s.URL})
+	a.Track("Something", map[string]interface{}{"n": 1})
+	a.Track("Else", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := s.Messages()
+
+	if len(m) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(m))
+	}
+
+	if m[0]["type"] != "track" || m[0]["event"] != "Something" || m[1]["event"] != "Else" {
+		t.Fatalf("expected the tracked events, got %v", m)
+	}
+
+	if p, _ := m[0]["properties"].(map[string]interface{}); p["n"] != 1.0 {
+		t.Fatalf("expected the properties, got %v", m[0]["properties"])
+	}
+}
@@ -0,0 +1,39 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnonymize(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".anonymize", AnonymizeKeys: []string{"email", "path"}})
+	props := func() map[string]interface{} {
+		return map[string]interface{}{"email": "tj@example.com", "path": "/home/tj", "command": "deploy"}
+	}
+
+	a.TrackAt("old", props(), time.Now().Add(-48*time.Hour))
+	a.Track("recent", props())
+
+	if err := a.Anonymize(24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := a.Events()
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+
+	if p := events[0].Properties; p["email"] != nil || p["path"] != nil || p["command"] != "deploy" {
+		t.Fatalf("expected the old event anonymized, got %v", p)
+	}
+
+	if events[0].Event != "old" || events[0].Timestamp.IsZero() {
+		t.Fatalf("expected the name and timestamp kept, got %+v", events[0])
+	}
+
+	if p := events[1].Properties; p["email"] != "tj@example.com" || p["path"] != "/home/tj" {
+		t.Fatalf("expected the recent event intact, got %v", p)
+	}
+}
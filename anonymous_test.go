@@ -0,0 +1,36 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestTrackAnonymous(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".anonymous", Endpoint: s.URL})
+	a.SetUserID("tj")
+	a.TrackAnonymous("Opened Login", nil)
+	a.Track("Deployed", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := s.Messages()
+
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+
+	if _, ok := msgs[0]["userId"]; ok || msgs[0]["anonymousId"] == nil {
+		t.Fatalf("expected only an anonymousId, got %v", msgs[0])
+	}
+
+	if msgs[1]["userId"] != "tj" {
+		t.Fatalf("expected the userId, got %v", msgs[1])
+	}
+}
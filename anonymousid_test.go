@@ -0,0 +1,30 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestAnonymousID(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".anon", Endpoint: s.URL})
+	a.Track("x", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := s.Messages()
+
+	if len(m) != 1 || m[0]["anonymousId"] != a.anonymousID || a.anonymousID == "" {
+		t.Fatalf("expected the anonymous id %q, got %v", a.anonymousID, m)
+	}
+
+	if _, ok := m[0]["userId"]; ok {
+		t.Fatalf("expected no user id, got %v", m[0]["userId"])
+	}
+}
@@ -0,0 +1,44 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestSetAppContext(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	for _, direct := range []bool{false, true} {
+		a := New(&Config{WriteKey: "k", Dir: t.TempDir(), Endpoint: s.URL, DirectHTTP: direct})
+		a.SetAppContext("up", "1.0.0")
+		a.SetOSContext("linux", "")
+		a.Track("x", nil)
+
+		if err := a.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := s.Messages()
+
+	if len(m) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(m))
+	}
+
+	for _, msg := range m {
+		c, _ := msg["context"].(map[string]interface{})
+		app, _ := c["app"].(map[string]interface{})
+		os, _ := c["os"].(map[string]interface{})
+
+		if app["name"] != "up" || app["version"] != "1.0.0" || os["name"] != "linux" {
+			t.Fatalf("expected the app and os context, got %v", c)
+		}
+
+		if v, ok := os["version"]; ok {
+			t.Fatalf("expected an empty os version omitted, got %v", v)
+		}
+	}
+}
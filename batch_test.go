@@ -0,0 +1,34 @@
+package analytics
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTrackBatch(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".batch"})
+	a.SetGlobalProperties(map[string]interface{}{"g": 1})
+
+	var events []*Event
+
+	for i := 0; i < 10; i++ {
+		events = append(events, &Event{Event: fmt.Sprint(i)})
+	}
+
+	if err := a.TrackBatch(events); err != nil {
+		t.Fatal(err)
+	}
+
+	buffered, _ := a.Events()
+
+	if len(buffered) != 10 {
+		t.Fatalf("expected 10 events, got %d", len(buffered))
+	}
+
+	for i, e := range buffered {
+		if e.Event != fmt.Sprint(i) || e.Properties["g"] != 1.0 {
+			t.Fatalf("expected event %d with the global properties, got %+v", i, e)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package analytics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBatchSize(t *testing.T) {
+	testHome(t)
+	var n int32
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+	}))
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".bs", Endpoint: s.URL, BatchSize: 2})
+
+	for i := 0; i < 5; i++ {
+		a.Track("x", nil)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 3 {
+		t.Fatalf("expected 3 requests, got %d", n)
+	}
+}
@@ -0,0 +1,64 @@
+package analytics
+
+import "testing"
+
+func TestBeforeSend(t *testing.T) {
+	testHome(t)
+	tr := &recordTransport{}
+	var dropped []error
+
+	a := New(&Config{
+		WriteKey:  "k",
+		Dir:       ".beforesend",
+		Transport: tr,
+		OnDrop: func(e *Event, err error) {
+			dropped = append(dropped, err)
+		},
+		BeforeSend: func(e *Event) *Event {
+			if e.Event == "secret" {
+				return nil
+			}
+			e.Properties = set(e.Properties, "sent_from_version", "1.2")
+			return e
+		},
+	})
+
+	a.Track("a", map[string]interface{}{"n": 1})
+	a.Track("secret", nil)
+	events, _ := a.Events()
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.events) != 1 || tr.events[0].Properties["sent_from_version"] != "1.2" {
+		t.Fatalf("expected 1 transformed event, got %v", tr.events)
+	}
+
+	if v, ok := events[0].Properties["sent_from_version"]; ok {
+		t.Fatalf("expected the buffered event unchanged, got %v", v)
+	}
+
+	if len(dropped) != 1 || dropped[0] != ErrDroppedBeforeSend {
+		t.Fatalf("expected ErrDroppedBeforeSend, got %v", dropped)
+	}
+}
+
+func TestBeforeSend_failure(t *testing.T) {
+	testHome(t)
+	m := NewMemStore()
+
+	a := New(&Config{WriteKey: "k", Dir: ".beforesend", Store: m, Transport: failTransport{}, BeforeSend: func(e *Event) *Event {
+		e.Properties = set(e.Properties, "x", 1)
+		return e
+	}})
+
+	a.Track("a", nil)
+	a.Flush()
+
+	events, _ := m.ReadAll()
+
+	if len(events) != 1 || events[0].Properties["x"] != nil {
+		t.Fatalf("expected the original event retained, got %v", events)
+	}
+}
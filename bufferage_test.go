@@ -0,0 +1,95 @@
+package analytics
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMaxBufferAge(t *testing.T) {
+	testHome(t)
+	c := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tr := &countTransport{}
+
+	a := New(&Config{
+		WriteKey:     "k",
+		Dir:          ".age",
+		Transport:    tr,
+		Clock:        c,
+		MaxBufferAge: time.Minute,
+	})
+
+	a.Track("a", nil)
+	a.Track("b", nil)
+
+	if tr.sends != 0 {
+		t.Fatalf("expected no flush, got %d", tr.sends)
+	}
+
+	c.t = c.t.Add(time.Minute)
+
+	if err := a.Track("c", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.n != 3 {
+		t.Fatalf("expected the new event to be flushed, got %d", tr.n)
+	}
+}
+
+func TestMaxBufferAge_failure(t *testing.T) {
+	testHome(t)
+	c := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tr := &countTransport{err: errors.New("boom")}
+
+	a := New(&Config{
+		WriteKey:     "k",
+		Dir:          ".age",
+		Transport:    tr,
+		Clock:        c,
+		MaxBufferAge: time.Hour,
+		FlushBackoff: time.Minute,
+	})
+
+	a.Track("a", nil)
+	c.t = c.t.Add(time.Hour)
+
+	if err := a.Track("b", nil); err != nil {
+		t.Fatalf("expected a best-effort flush, got %s", err)
+	}
+
+	if n, _ := a.Size(); n != 2 {
+		t.Fatalf("expected the event to be buffered, got %d", n)
+	}
+
+	if m := a.Metrics(); m.FlushFailures != 1 {
+		t.Fatalf("expected a flush failure, got %d", m.FlushFailures)
+	}
+
+	// backing off
+	a.Track("c", nil)
+	c.t = c.t.Add(59 * time.Second)
+	a.Track("d", nil)
+
+	if tr.sends != 1 {
+		t.Fatalf("expected to back off, got %d sends", tr.sends)
+	}
+
+	// retry, doubling the backoff
+	c.t = c.t.Add(time.Second)
+	a.Track("e", nil)
+	c.t = c.t.Add(time.Minute)
+	a.Track("f", nil)
+
+	if tr.sends != 2 {
+		t.Fatalf("expected a single retry, got %d sends", tr.sends)
+	}
+
+	tr.err = nil
+	c.t = c.t.Add(time.Minute)
+	a.Track("g", nil)
+
+	if tr.n != 7 {
+		t.Fatalf("expected all events delivered, got %d", tr.n)
+	}
+}
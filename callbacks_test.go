@@ -0,0 +1,83 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOnFlush(t *testing.T) {
+	testHome(t)
+	var n int
+	var ferr error
+	var m Metrics
+	var a *Analytics
+
+	a = New(&Config{
+		WriteKey:  "k",
+		Dir:       ".cb",
+		Transport: &countTransport{},
+		OnFlush: func(count int, err error) {
+			n, ferr = count, err
+			m = a.Metrics()
+		},
+	})
+
+	a.Track("x", nil)
+	a.Track("y", nil)
+
+	within(t, 2*time.Second, func() {
+		if err := a.Flush(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if n != 2 || ferr != nil {
+		t.Fatalf("expected 2 events flushed, got %d and %v", n, ferr)
+	}
+
+	if m.Flushed != 2 {
+		t.Fatalf("expected the metrics after the flush, got %+v", m)
+	}
+
+	b := New(&Config{
+		WriteKey:  "k",
+		Dir:       ".cb2",
+		Transport: failTransport{},
+		OnFlush:   func(count int, err error) { n, ferr = count, err },
+	})
+
+	b.Track("x", nil)
+	b.Flush()
+
+	if n != 0 || ferr == nil {
+		t.Fatalf("expected the flush error, got %d and %v", n, ferr)
+	}
+}
+
+func TestOnDrop(t *testing.T) {
+	testHome(t)
+	var dropped []string
+	var sizes []int
+	var a *Analytics
+
+	a = New(&Config{
+		WriteKey:       "k",
+		Dir:            ".cb",
+		DisabledEvents: []string{"secret"},
+		OnDrop: func(e *Event, err error) {
+			n, _ := a.Size()
+			dropped = append(dropped, e.Event)
+			sizes = append(sizes, n)
+		},
+	})
+
+	a.Track("x", nil)
+
+	within(t, 2*time.Second, func() {
+		a.Track("secret", nil)
+	})
+
+	if len(dropped) != 1 || dropped[0] != "secret" || sizes[0] != 1 {
+		t.Fatalf("expected the dropped event, got %v and %v", dropped, sizes)
+	}
+}
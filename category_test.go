@@ -0,0 +1,42 @@
+package analytics
+
+import "testing"
+
+func TestRequireCategoryConsent(t *testing.T) {
+	testHome(t)
+	a := New(&Config{
+		WriteKey:               "k",
+		Dir:                    ".category",
+		RequireCategoryConsent: true,
+		Categories:             map[string]string{"Crash": "errors", "Deploy": "usage"},
+	})
+
+	a.Track("Crash", nil)
+	a.Track("Deploy", nil)
+	a.Track("Other", nil)
+
+	if events, _ := a.Events(); len(events) != 1 || events[0].Event != "Other" {
+		t.Fatalf("expected only the uncategorized event, got %v", events)
+	}
+
+	if err := a.ConsentCategory("errors"); err != nil {
+		t.Fatal(err)
+	}
+
+	a.Track("Crash", nil)
+	a.Track("Deploy", nil)
+
+	if events, _ := a.Events(); len(events) != 2 || events[1].Event != "Crash" {
+		t.Fatalf("expected the consented category tracked, got %v", events)
+	}
+
+	if err := a.RevokeCategory("errors"); err != nil {
+		t.Fatal(err)
+	}
+
+	a.Track("Crash", nil)
+
+	if n, _ := a.Size(); n != 2 {
+		t.Fatalf("expected the revoked category dropped, got %d", n)
+	}
+}
@@ -0,0 +1,48 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFlushClearMode_truncate(t *testing.T) {
+	home := testHome(t)
+	path := filepath.Join(home, ".clear", "events")
+
+	a := New(&Config{WriteKey: "k", Dir: ".clear", Transport: &countTransport{}, FlushClearMode: ClearTruncate})
+	a.Track("x", nil)
+
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected the events file kept, got %s", err)
+	}
+
+	if after.Size() != 0 || !os.SameFile(before, after) {
+		t.Fatalf("expected the same file truncated, got %d bytes", after.Size())
+	}
+}
+
+func TestFlushClearMode_remove(t *testing.T) {
+	home := testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".clear", Transport: &countTransport{}})
+	a.Track("x", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(home, ".clear", "events")); !os.IsNotExist(err) {
+		t.Fatalf("expected the events file removed, got %v", err)
+	}
+}
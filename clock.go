@@ -0,0 +1,28 @@
+package analytics
+
+import "time"
+
+// Clock provides the current time, letting tests control timestamps
+// and age-based flushing.
+type Clock interface {
+	Now() time.Time
+}
+
+// Sleeper may be implemented by a Clock to wait for a duration on its
+// time, such as when BlockOnRateLimit waits, falling back to time.Sleep.
+type Sleeper interface {
+	Sleep(d time.Duration)
+}
+
+// wallClock is the default Clock.
+type wallClock struct{}
+
+// Now implementation.
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep implementation.
+func (wallClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
@@ -0,0 +1,44 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestClock(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	c := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	a := New(&Config{WriteKey: "k", Dir: ".clock", Endpoint: s.URL, Clock: c})
+
+	e, _ := a.TrackEvent("x", nil)
+
+	if !e.Timestamp.Equal(c.t) {
+		t.Fatalf("expected the clock time, got %s", e.Timestamp)
+	}
+
+	c.t = c.t.Add(time.Hour - time.Second)
+
+	if err := a.ConditionalFlush(100, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.Messages()); n != 0 {
+		t.Fatalf("expected no flush before the hour, got %d messages", n)
+	}
+
+	c.t = c.t.Add(time.Second)
+	b := New(&Config{WriteKey: "k", Dir: ".clock", Endpoint: s.URL, Clock: c})
+
+	if err := b.ConditionalFlush(100, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.Messages()); n != 1 {
+		t.Fatalf("expected a flush at the hour, got %d messages", n)
+	}
+}
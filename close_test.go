@@ -0,0 +1,28 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClose_disabled(t *testing.T) {
+	testHome(t)
+	t.Setenv("DO_NOT_TRACK", "1")
+	a := New(&Config{WriteKey: "k", Dir: ".close"})
+
+	if err := a.ConditionalFlush(0, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
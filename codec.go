@@ -0,0 +1,52 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Codec encodes and decodes the records of the events file, one per line,
+// such as to use a faster JSON implementation. Encoded records must not
+// contain newlines.
+type Codec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(b []byte, v interface{}) error
+}
+
+// jsonCodec is the default Codec, using encoding/json.
+type jsonCodec struct{}
+
+// Encode implementation.
+func (jsonCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Decode implementation.
+func (jsonCodec) Decode(b []byte, v interface{}) error {
+	return json.Unmarshal(b, v)
+}
+
+// recordEncoder writes each record encoded by the Codec as a line,
+// in a single Write so that each may be compressed or sealed.
+type recordEncoder struct {
+	w     io.Writer
+	codec Codec
+}
+
+// Encode writes `v` as a line.
+func (e *recordEncoder) Encode(v interface{}) error {
+	b, err := e.codec.Encode(v)
+	if err != nil {
+		return err
+	}
+
+	if bytes.IndexByte(b, '\n') != -1 {
+		return errors.New("encoded record contains a newline")
+	}
+
+	_, err = e.w.Write(append(b, '\n'))
+	return err
+}
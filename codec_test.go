@@ -0,0 +1,46 @@
+package analytics
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// countCodec is a JSON Codec counting its calls.
+type countCodec struct {
+	enc, dec int
+}
+
+func (c *countCodec) Encode(v interface{}) ([]byte, error) {
+	c.enc++
+	return json.Marshal(v)
+}
+
+func (c *countCodec) Decode(b []byte, v interface{}) error {
+	c.dec++
+	return json.Unmarshal(b, v)
+}
+
+func TestCodec(t *testing.T) {
+	testHome(t)
+
+	for _, strict := range []bool{false, true} {
+		c := &countCodec{}
+		a := New(&Config{WriteKey: "k", Dir: t.TempDir(), Codec: c, StrictDecode: strict, EventsHeader: true})
+		a.Track("a", nil)
+		a.Track("b", nil)
+
+		if c.enc < 2 {
+			t.Fatalf("expected the codec to encode events, got %d calls", c.enc)
+		}
+
+		events, err := a.Events()
+
+		if err != nil || len(events) != 2 || events[1].Event != "b" {
+			t.Fatalf("expected 2 events with strict=%v, got %v and %v", strict, events, err)
+		}
+
+		if c.dec < 2 {
+			t.Fatalf("expected the codec to decode events, got %d calls", c.dec)
+		}
+	}
+}
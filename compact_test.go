@@ -0,0 +1,88 @@
+package analytics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	testHome(t)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &fakeClock{t: start}
+	a := New(&Config{WriteKey: "k", Dir: ".compact", Clock: c, MaxRetryAge: time.Hour, MaxBytes: 200})
+
+	for _, name := range []string{"old1", "fresh1", "old2", "fresh2"} {
+		c.t = start
+
+		if strings.HasPrefix(name, "fresh") {
+			c.t = start.Add(2 * time.Hour)
+		}
+
+		a.Track(name, nil)
+	}
+
+	if err := a.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := a.Events()
+
+	if len(events) != 2 || events[0].Event != "fresh1" || events[1].Event != "fresh2" {
+		t.Fatalf("expected the expired events removed, got %v", events)
+	}
+
+	a.Track("fresh3", nil)
+
+	if n, _ := a.Size(); n != 3 {
+		t.Fatalf("expected tracking to continue, got %d", n)
+	}
+}
+
+func TestCompact_order(t *testing.T) {
+	testHome(t)
+
+	for _, names := range [][]string{{"x", "x", "y"}, {"x", "x", "x"}} {
+		a := New(&Config{WriteKey: "k", Dir: ".compact", Dedup: true})
+
+		for _, name := range names {
+			a.Track(name, map[string]interface{}{"a": 1})
+		}
+
+		a.Close()
+	}
+
+	a := New(&Config{WriteKey: "k", Dir: ".compact", Dedup: true})
+
+	if err := a.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := a.Events()
+
+	if len(events) != 3 || count(events[0]) != 2 || count(events[2]) != 3 {
+		t.Fatalf("expected non-consecutive duplicates kept, got %v", events)
+	}
+}
+
+func TestCompact_collapse(t *testing.T) {
+	testHome(t)
+
+	for i := 0; i < 3; i++ {
+		a := New(&Config{WriteKey: "k", Dir: ".compact", Dedup: true})
+		a.Track("x", nil)
+		a.Close()
+	}
+
+	a := New(&Config{WriteKey: "k", Dir: ".compact", Dedup: true})
+
+	if err := a.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := a.Events()
+
+	if len(events) != 1 || count(events[0]) != 3 {
+		t.Fatalf("expected 1 event with a count of 3, got %v", events)
+	}
+}
@@ -0,0 +1,217 @@
+package analytics
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// gzipMagic is the header every gzip member starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// frameMagic is the first byte of each record compressed by a Compressor,
+// followed by the length-prefixed Name and compressed record.
+const frameMagic = 0x00
+
+// Compressor compresses each buffered event when Compress is enabled, such
+// as for a better ratio than gzip. Each record is framed with the Name of
+// its Compressor, so that reading it with another one fails.
+type Compressor interface {
+	Name() string
+	Compress(p []byte) ([]byte, error)
+	Decompress(p []byte) ([]byte, error)
+}
+
+// encoder returns a Codec encoder for `w`, compressing each event when
+// Compress is enabled and then sealing it when an EncryptionKey is set,
+// as ciphertext doesn't compress.
+func (a *Analytics) encoder(w io.Writer) *recordEncoder {
+	if a.aead != nil {
+		w = encryptWriter{w: w, aead: a.aead}
+	}
+
+	switch {
+	case a.Compress && a.Compressor != nil:
+		w = compressWriter{w: w, c: a.Compressor}
+	case a.Compress:
+		w = gzipWriter{w}
+	}
+
+	return &recordEncoder{w: w, codec: a.Codec}
+}
+
+// plaintext returns a reader of the event lines of `r`, decrypting and
+// then decompressing them as necessary. Records compressed before an
+// EncryptionKey was set are decompressed before decrypting.
+func (a *Analytics) plaintext(r io.Reader) io.Reader {
+	r = decompress(r, a.Compressor)
+
+	if a.aead != nil {
+		r = decompress(&decryptReader{r: bufio.NewReader(r), aead: a.aead}, a.Compressor)
+	}
+
+	return r
+}
+
+// gzipWriter writes each Write as an independent gzip member, so that
+// events may be appended to the file without rewriting it.
+type gzipWriter struct {
+	w io.Writer
+}
+
+// Write implementation.
+func (g gzipWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+
+	if _, err := z.Write(p); err != nil {
+		return 0, err
+	}
+
+	if err := z.Close(); err != nil {
+		return 0, err
+	}
+
+	if _, err := g.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// compressWriter writes each Write as a record compressed by a Compressor.
+type compressWriter struct {
+	w io.Writer
+	c Compressor
+}
+
+// Write implementation.
+func (c compressWriter) Write(p []byte) (int, error) {
+	name := c.c.Name()
+	if name == "" || len(name) > 255 {
+		return 0, errors.Errorf("invalid compressor name %q", name)
+	}
+
+	b, err := c.c.Compress(p)
+	if err != nil {
+		return 0, errors.Wrap(err, "compressing")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(frameMagic)
+	buf.WriteByte(byte(len(name)))
+	buf.WriteString(name)
+	binary.Write(&buf, binary.BigEndian, uint32(len(b)))
+	buf.Write(b)
+
+	if _, err := c.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// decompress returns a reader of the plaintext lines in `r`, which may
+// contain any mix of plaintext lines, gzip members, and records of
+// Compressor `c`, such as when Compress has been toggled between runs.
+func decompress(r io.Reader, c Compressor) io.Reader {
+	return &framedReader{r: bufio.NewReader(r), c: c}
+}
+
+// framedReader reads plaintext lines, gzip members,
+// and records of a Compressor in turn.
+type framedReader struct {
+	r   *bufio.Reader
+	c   Compressor
+	cur io.Reader
+}
+
+// Read implementation.
+func (f *framedReader) Read(p []byte) (int, error) {
+	for {
+		if f.cur != nil {
+			n, err := f.cur.Read(p)
+
+			if err == io.EOF {
+				f.cur = nil
+				if n == 0 {
+					continue
+				}
+				err = nil
+			}
+
+			return n, err
+		}
+
+		magic, err := f.r.Peek(len(gzipMagic))
+		if len(magic) == 0 {
+			return 0, err
+		}
+
+		if bytes.Equal(magic, gzipMagic) {
+			z, err := gzip.NewReader(f.r)
+			if err != nil {
+				return 0, err
+			}
+
+			z.Multistream(false)
+			f.cur = z
+			continue
+		}
+
+		if magic[0] == frameMagic {
+			b, err := f.frame()
+			if err != nil {
+				return 0, err
+			}
+
+			f.cur = bytes.NewReader(b)
+			continue
+		}
+
+		line, err := f.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		f.cur = bytes.NewReader(line)
+	}
+}
+
+// frame reads and decompresses a record of a Compressor, failing
+// when it was written by another one than the configured Compressor.
+func (f *framedReader) frame() ([]byte, error) {
+	var head [2]byte
+
+	if _, err := io.ReadFull(f.r, head[:]); err != nil {
+		return nil, errors.Wrap(err, "reading frame")
+	}
+
+	name := make([]byte, head[1])
+
+	if _, err := io.ReadFull(f.r, name); err != nil {
+		return nil, errors.Wrap(err, "reading frame")
+	}
+
+	var n uint32
+
+	if err := binary.Read(f.r, binary.BigEndian, &n); err != nil {
+		return nil, errors.Wrap(err, "reading frame")
+	}
+
+	b := make([]byte, n)
+
+	if _, err := io.ReadFull(f.r, b); err != nil {
+		return nil, errors.Wrap(err, "reading frame")
+	}
+
+	if f.c == nil || f.c.Name() != string(name) {
+		return nil, errors.Errorf("event compressed with %q, not the configured compressor", name)
+	}
+
+	return f.c.Decompress(b)
+}
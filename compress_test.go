@@ -0,0 +1,140 @@
+package analytics
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// flateCompressor is a Compressor using compress/flate.
+type flateCompressor struct {
+	name string
+}
+
+func (c flateCompressor) Name() string { return c.name }
+
+func (c flateCompressor) Compress(p []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write(p)
+	err := w.Close()
+	return buf.Bytes(), err
+}
+
+func (c flateCompressor) Decompress(p []byte) ([]byte, error) {
+	return ioutil.ReadAll(flate.NewReader(bytes.NewReader(p)))
+}
+
+func TestCompress(t *testing.T) {
+	home := testHome(t)
+	var out bytes.Buffer
+
+	a := New(&Config{WriteKey: "k", Dir: ".gz", Output: &out})
+	a.Track("plain", map[string]interface{}{"a": 1})
+	a.Close()
+
+	a = New(&Config{WriteKey: "k", Dir: ".gz", Compress: true, Output: &out})
+	a.Track("zipped", map[string]interface{}{"b": 2})
+	a.Track("zipped2", nil)
+
+	b, _ := ioutil.ReadFile(filepath.Join(home, ".gz", "events"))
+
+	if !bytes.Contains(b, gzipMagic) {
+		t.Fatal("expected gzipped events")
+	}
+
+	events, err := a.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 3 || events[0].Event != "plain" || events[1].Event != "zipped" {
+		t.Fatalf("expected the plaintext and gzipped events, got %v", events)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(out.Bytes(), []byte("zipped2")) {
+		t.Fatalf("expected the events flushed, got %s", out.String())
+	}
+}
+
+func TestCompressor(t *testing.T) {
+	home := testHome(t)
+	c := flateCompressor{name: "flate"}
+
+	a := New(&Config{WriteKey: "k", Dir: ".cmp", Compress: true})
+	a.Track("gzipped", nil)
+	a.Close()
+
+	a = New(&Config{WriteKey: "k", Dir: ".cmp", Compress: true, Compressor: c})
+	a.Track("flated", map[string]interface{}{"value": "hello"})
+
+	b, _ := ioutil.ReadFile(filepath.Join(home, ".cmp", "events"))
+
+	if !bytes.Contains(b, []byte("\x00\x05flate")) {
+		t.Fatal("expected the compressor to be recorded")
+	}
+
+	events, err := a.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 || events[1].Event != "flated" || events[1].Properties["value"] != "hello" {
+		t.Fatalf("expected the events read back, got %v", events)
+	}
+}
+
+func TestCompressor_mismatch(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".cmp", Compress: true, Compressor: flateCompressor{name: "flate"}})
+	a.Track("x", nil)
+	a.Close()
+
+	for _, c := range []Compressor{nil, flateCompressor{name: "other"}} {
+		b := New(&Config{WriteKey: "k", Dir: ".cmp", Compress: true, Compressor: c})
+
+		if _, err := b.Events(); err == nil {
+			t.Fatalf("expected an error reading with %v", c)
+		}
+
+		b.Close()
+	}
+}
+
+func TestCompress_size(t *testing.T) {
+	home := testHome(t)
+
+	for _, dir := range []string{".plain", ".gz"} {
+		a := New(&Config{WriteKey: "k", Dir: dir, Compress: dir == ".gz"})
+		props := map[string]interface{}{}
+
+		for i := 0; i < 50; i++ {
+			props[fmt.Sprintf("flag_%d", i)] = "enabled"
+		}
+
+		for i := 0; i < 100; i++ {
+			a.Track("Command Run", props)
+		}
+
+		a.Close()
+	}
+
+	plain, _ := ioutil.ReadFile(filepath.Join(home, ".plain", "events"))
+	gz, _ := ioutil.ReadFile(filepath.Join(home, ".gz", "events"))
+
+	if len(gz) >= len(plain) {
+		t.Fatalf("expected the gzipped buffer smaller than %d bytes, got %d", len(plain), len(gz))
+	}
+
+	if n, _ := New(&Config{WriteKey: "k", Dir: ".gz", Compress: true}).Size(); n != 100 {
+		t.Fatalf("expected 100 events, got %d", n)
+	}
+}
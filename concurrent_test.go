@@ -0,0 +1,70 @@
+package analytics
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestConcurrentTrack(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".conc"})
+	defer a.Close()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := a.Track("x", map[string]interface{}{"j": j}); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	n, err := a.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 5000 {
+		t.Fatalf("expected 5000 events, got %d", n)
+	}
+}
+
+func TestConcurrentTrack_flush(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+	a := New(&Config{WriteKey: "k", Dir: ".conc", Transport: tr, Store: NewMemStore()})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				a.Track("x", nil)
+				a.Size()
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			a.Flush()
+			a.Metrics()
+		}()
+	}
+
+	wg.Wait()
+	a.Flush()
+
+	if tr.n != 1000 {
+		t.Fatalf("expected 1000 events delivered, got %d", tr.n)
+	}
+}
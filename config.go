@@ -0,0 +1,138 @@
+package analytics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fileConfig is the format of ~/<dir>/config.json, letting operators adjust
+// settings without recompiling the program. Explicit Config values take
+// precedence over the file, which takes precedence over the defaults.
+type fileConfig struct {
+	WriteKey         string   `json:"write_key"`
+	Endpoint         string   `json:"endpoint"`
+	MinEventsToFlush int      `json:"min_events_to_flush"`
+	MaxBufferAge     string   `json:"max_buffer_age"`
+	FlushEveryNRuns  int      `json:"flush_every_n_runs"`
+	DisabledEvents   []string `json:"disabled_events"`
+}
+
+// init WriteKey when empty from the WriteKeyEnv environment
+// variable, or otherwise the WriteKeyFile.
+func (a *Analytics) initWriteKey() error {
+	if a.WriteKey != "" {
+		return nil
+	}
+
+	if v := strings.TrimSpace(os.Getenv(a.WriteKeyEnv)); v != "" {
+		a.Log.WithField("env", a.WriteKeyEnv).Debug("using write key from environment")
+		a.WriteKey = v
+		return nil
+	}
+
+	if a.WriteKeyFile == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(a.WriteKeyFile)
+	if err != nil {
+		return errors.Wrap(err, "reading write key file")
+	}
+
+	a.Log.Debug("using write key from file")
+	a.WriteKey = strings.TrimSpace(string(b))
+	return nil
+}
+
+// init ~/<dir>/config.json, merging its values under the Config.
+func (a *Analytics) initConfigFile() {
+	b, err := ioutil.ReadFile(filepath.Join(a.root, "config.json"))
+	if os.IsNotExist(err) {
+		return
+	}
+
+	if err != nil {
+		a.Log.WithError(err).Debug("error reading config file")
+		return
+	}
+
+	var c fileConfig
+
+	if err := json.Unmarshal(b, &c); err != nil {
+		a.Log.WithError(err).Debug("error parsing config file")
+		return
+	}
+
+	a.Log.Debug("merging config file")
+
+	if a.WriteKey == "" {
+		a.WriteKey = c.WriteKey
+	}
+
+	if a.Endpoint == "" {
+		a.Endpoint = c.Endpoint
+	}
+
+	if a.MinEventsToFlush == 0 {
+		a.MinEventsToFlush = c.MinEventsToFlush
+	}
+
+	if a.MaxBufferAge == 0 && c.MaxBufferAge != "" {
+		d, err := time.ParseDuration(c.MaxBufferAge)
+		if err != nil {
+			a.Log.WithError(err).Debug("error parsing max_buffer_age")
+		} else {
+			a.MaxBufferAge = d
+		}
+	}
+
+	if a.FlushEveryNRuns == 0 {
+		a.FlushEveryNRuns = c.FlushEveryNRuns
+	}
+
+	if a.DisabledEvents == nil {
+		a.DisabledEvents = c.DisabledEvents
+	}
+}
+
+// validate the merged config. A WriteKey is required, from the Config,
+// environment, key file or config file, unless events are delivered elsewhere.
+func (a *Analytics) validate() error {
+	if a.WriteKey == "" && !a.DryRun && a.Transport == nil && a.Output == nil {
+		return errors.New("write key is required")
+	}
+
+	if _, err := newAEAD(a.EncryptionKey); err != nil {
+		return err
+	}
+
+	if ns := a.Namespace; ns != "" && (filepath.Base(ns) != ns || ns == "." || ns == "..") {
+		return errors.Errorf("invalid namespace %q", ns)
+	}
+
+	if r := a.SampleRate; r != nil && (*r < 0 || *r > 1) {
+		return errors.Errorf("sample rate %v must be between 0 and 1", *r)
+	}
+
+	if a.Endpoint == "" {
+		return nil
+	}
+
+	u, err := url.Parse(a.Endpoint)
+	if err != nil {
+		return errors.Wrap(err, "parsing endpoint")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return errors.Errorf("invalid endpoint %q", a.Endpoint)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfig writes ~/<dir>/config.json.
+func writeConfig(t *testing.T, dir, s string) {
+	t.Helper()
+	home, _ := os.UserHomeDir()
+	path := filepath.Join(home, dir)
+
+	if err := os.MkdirAll(path, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(path, "config.json"), []byte(s), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	testHome(t)
+	writeConfig(t, ".cfg", `{"write_key": "file", "max_buffer_age": "1h", "min_events_to_flush": 5}`)
+
+	a := New(&Config{Dir: ".cfg", MinEventsToFlush: 2})
+
+	if a.WriteKey != "file" || a.MaxBufferAge != time.Hour {
+		t.Fatalf("expected the file's values, got %q and %s", a.WriteKey, a.MaxBufferAge)
+	}
+
+	if a.MinEventsToFlush != 2 {
+		t.Fatalf("expected the Config to take precedence, got %d", a.MinEventsToFlush)
+	}
+}
+
+func TestConfigFile_invalidDuration(t *testing.T) {
+	testHome(t)
+	writeConfig(t, ".cfg", `{"write_key": "file", "max_buffer_age": "soon"}`)
+
+	a := New(&Config{Dir: ".cfg"})
+
+	if a.MaxBufferAge != 0 || a.WriteKey != "file" {
+		t.Fatalf("expected the invalid duration to be skipped, got %s", a.MaxBufferAge)
+	}
+}
+
+func TestConfigFile_precedence(t *testing.T) {
+	testHome(t)
+	writeConfig(t, ".cfg", `{"write_key": "file", "endpoint": "http://file", "flush_every_n_runs": 3, "disabled_events": ["noisy"]}`)
+
+	a := New(&Config{WriteKey: "explicit", Dir: ".cfg"})
+
+	if a.WriteKey != "explicit" || a.Endpoint != "http://file" || a.FlushEveryNRuns != 3 {
+		t.Fatalf("expected explicit values over the file, got %q, %q and %d", a.WriteKey, a.Endpoint, a.FlushEveryNRuns)
+	}
+
+	a.Track("noisy", nil)
+	a.Track("other", nil)
+
+	if n, _ := a.Size(); n != 1 {
+		t.Fatalf("expected the file's disabled events dropped, got %d", n)
+	}
+}
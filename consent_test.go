@@ -0,0 +1,62 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGrantConsent(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".consent", ConsentRequired: true})
+	if ok, _ := a.Enabled(); ok {
+		t.Fatal("expected disabled before consent")
+	}
+
+	a.Track("before", nil)
+
+	if err := a.GrantConsent(); err != nil {
+		t.Fatal(err)
+	}
+
+	a.Track("granted", nil)
+
+	if err := a.RevokeConsent(); err != nil {
+		t.Fatal(err)
+	}
+
+	a.Track("revoked", nil)
+
+	b := New(&Config{WriteKey: "k", Dir: ".consent"})
+	events, _ := b.Events()
+	if len(events) != 1 || events[0].Event != "granted" {
+		t.Fatalf("unexpected events %v", events)
+	}
+}
+
+func TestGrantConsent_firstRun(t *testing.T) {
+	testHome(t)
+
+	var a *Analytics
+	var size int
+
+	a = New(&Config{
+		WriteKey:        "k",
+		Dir:             ".consent",
+		ConsentRequired: true,
+		TrackFirstRun:   true,
+		OnFirstRun: func() {
+			size, _ = a.Size()
+		},
+	})
+
+	within(t, 2*time.Second, func() {
+		if err := a.GrantConsent(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if !a.FirstRun() || size != 1 {
+		t.Fatalf("expected first run with one event, got %v and %d", a.FirstRun(), size)
+	}
+}
@@ -0,0 +1,65 @@
+package analytics
+
+// SetContext replaces the context sent with every event, such as
+// the program version, OS, or locale. Unlike properties the context
+// is not buffered with events, the current context is sent on Flush.
+func (a *Analytics) SetContext(ctx map[string]interface{}) {
+	a.mu.Lock()
+	defer a.unlock()
+	a.waitFlush()
+
+	a.eventContext = make(map[string]interface{})
+
+	for k, v := range ctx {
+		a.eventContext[k] = v
+	}
+}
+
+// AddContextField sets context field `key` to `value`.
+func (a *Analytics) AddContextField(key string, value interface{}) {
+	a.mu.Lock()
+	defer a.unlock()
+	a.waitFlush()
+
+	if a.eventContext == nil {
+		a.eventContext = make(map[string]interface{})
+	}
+
+	a.eventContext[key] = value
+}
+
+// context returns the context, or nil when empty.
+func (a *Analytics) context() map[string]interface{} {
+	if len(a.eventContext) == 0 {
+		return nil
+	}
+
+	return a.eventContext
+}
+
+// SetAppContext sets Segment's "app" context to the program `name` and
+// `version`, which powers its built-in reports unlike arbitrary fields.
+func (a *Analytics) SetAppContext(name, version string) {
+	a.setContextObject("app", name, version)
+}
+
+// SetOSContext sets Segment's "os" context to the OS `name` and `version`.
+func (a *Analytics) SetOSContext(name, version string) {
+	a.setContextObject("os", name, version)
+}
+
+// setContextObject sets context field `key` to an object with the given
+// name and version, omitting them when empty.
+func (a *Analytics) setContextObject(key, name, version string) {
+	v := make(map[string]interface{})
+
+	if name != "" {
+		v["name"] = name
+	}
+
+	if version != "" {
+		v["version"] = version
+	}
+
+	a.AddContextField(key, v)
+}
@@ -0,0 +1,51 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestContext(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	for _, direct := range []bool{false, true} {
+		a := New(&Config{WriteKey: "k", Dir: t.TempDir(), Endpoint: s.URL, DirectHTTP: direct, Context: map[string]interface{}{"version": "1.2.3"}})
+		a.AddContextField("os", "linux")
+		a.Track("x", nil)
+
+		if err := a.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := s.Messages()
+
+	if len(m) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(m))
+	}
+
+	for _, msg := range m {
+		c, _ := msg["context"].(map[string]interface{})
+
+		if c["version"] != "1.2.3" || c["os"] != "linux" {
+			t.Fatalf("expected the global context, got %v", msg["context"])
+		}
+	}
+}
+
+func TestContext_unset(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".context", Endpoint: s.URL})
+	a.Track("y", nil)
+	a.Flush()
+
+	if c, ok := s.Messages()[0]["context"]; ok {
+		t.Fatalf("expected no context, got %v", c)
+	}
+}
@@ -0,0 +1,39 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvents_truncated(t *testing.T) {
+	home := testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".corrupt"})
+
+	for i := 0; i < 3; i++ {
+		a.Track("x", nil)
+	}
+
+	a.Close()
+
+	f, _ := os.OpenFile(filepath.Join(home, ".corrupt", "events"), os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"event":"y","prop`)
+	f.Close()
+
+	b := New(&Config{WriteKey: "k", Dir: ".corrupt"})
+
+	if events, err := b.Events(); err != nil || len(events) != 3 {
+		t.Fatalf("expected the truncated line skipped, got %d and %v", len(events), err)
+	}
+
+	if n, err := b.Size(); n != 3 || err != nil {
+		t.Fatalf("expected 3 events, got %d and %v", n, err)
+	}
+
+	c := New(&Config{WriteKey: "k", Dir: ".corrupt", StrictDecode: true})
+
+	if _, err := c.Events(); err == nil {
+		t.Fatal("expected an error with StrictDecode")
+	}
+}
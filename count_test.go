@@ -0,0 +1,76 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestSize_cached(t *testing.T) {
+	home := testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".count", Endpoint: s.URL, EventsHeader: true})
+	a.Track("a", nil)
+	a.Close()
+
+	b := New(&Config{WriteKey: "k", Dir: ".count", Endpoint: s.URL, EventsHeader: true})
+	b.Track("b", nil)
+
+	if n, _ := b.Size(); n != 2 {
+		t.Fatalf("expected 2 events, got %d", n)
+	}
+
+	b.Track("c", nil)
+
+	if n, _ := b.Size(); n != 3 || b.countSize < 0 {
+		t.Fatalf("expected a cached count of 3, got %d", n)
+	}
+
+	f, _ := os.OpenFile(filepath.Join(home, ".count", "events"), os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"event":"ext"}` + "\n")
+	f.Close()
+
+	if n, _ := b.Size(); n != 4 {
+		t.Fatalf("expected an external write to invalidate the count, got %d", n)
+	}
+}
+
+func TestSize_flush(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".count", Endpoint: s.URL, FlushClearMode: ClearTruncate})
+	a.Track("d", nil)
+
+	if n, _ := a.Size(); n != 1 {
+		t.Fatalf("expected 1 event, got %d", n)
+	}
+
+	a.Flush()
+	a.initEvents()
+	a.Track("e", nil)
+
+	if n, _ := a.Size(); n != 1 {
+		t.Fatalf("expected the count reset by Flush, got %d", n)
+	}
+}
+
+func BenchmarkSize(b *testing.B) {
+	testHome(b)
+	a := New(&Config{WriteKey: "k", Dir: ".count"})
+
+	for i := 0; i < 500; i++ {
+		a.Track("x", map[string]interface{}{"i": i})
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		a.Size()
+	}
+}
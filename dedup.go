@@ -0,0 +1,188 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// written is the last event written to the buffer, for Dedup.
+type written struct {
+	event  *Event
+	key    []byte
+	offset int64 // offset of the event in the events file
+	end    int64 // end of the event in the events file
+}
+
+// dedupKey returns the key of `e` compared by Dedup, its name and
+// properties other than "count" and those injected when tracking, the
+// "previous_event" and SequenceProperty, which differ between otherwise
+// identical events.
+func (a *Analytics) dedupKey(e *Event) []byte {
+	props := make(map[string]interface{}, len(e.Properties))
+
+	for k, v := range e.Properties {
+		if k == "count" || (a.TrackPreviousEvent && k == "previous_event") || (a.SequenceProperty != "" && k == a.SequenceProperty) {
+			continue
+		}
+
+		props[k] = v
+	}
+
+	b, err := json.Marshal(struct {
+		Event      string                 `json:"event"`
+		Properties map[string]interface{} `json:"properties"`
+	}{e.Event, props})
+
+	if err != nil {
+		return nil
+	}
+
+	return b
+}
+
+// remember `e` as the last event written, at `offset` of the events file.
+func (a *Analytics) remember(e *Event, offset int64) {
+	if !a.Dedup || e.Priority == PriorityHigh {
+		return
+	}
+
+	w := &written{
+		event:  e,
+		key:    a.dedupKey(e),
+		offset: offset,
+	}
+
+	if a.store == nil {
+		info, err := a.eventsFile.Stat()
+		if err != nil || offset < 0 {
+			a.last = nil
+			return
+		}
+
+		w.end = info.Size()
+	}
+
+	a.last = w
+}
+
+// dedup collapses `e` into the last event written when they're identical,
+// incrementing its "count" property, returning the collapsed event or nil. The
+// last event is rewritten in place, which is only possible while it's
+// still the last in the buffer.
+func (a *Analytics) dedup(e *Event) (*Event, error) {
+	last := a.last
+
+	if last == nil || e.Priority == PriorityHigh || a.SocketPath != "" {
+		return nil, nil
+	}
+
+	if last.key == nil || !bytes.Equal(last.key, a.dedupKey(e)) {
+		return nil, nil
+	}
+
+	unlock, err := a.lockBuffer()
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+
+	if a.store != nil {
+		return a.dedupStore(last)
+	}
+
+	if err := a.reopenMoved(); err != nil {
+		return nil, err
+	}
+
+	info, err := a.eventsFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size() != last.end {
+		a.last = nil
+		return nil, nil
+	}
+
+	if err := a.eventsFile.Truncate(last.offset); err != nil {
+		return nil, err
+	}
+
+	increment(last.event)
+
+	if err := a.events.Encode(last.event); err != nil {
+		return nil, err
+	}
+
+	a.remember(last.event, last.offset)
+
+	if a.countSize >= 0 {
+		a.cacheSize()
+	}
+
+	if a.Durability >= DurabilityOnEveryWrite {
+		return last.event, syncFile(a.eventsFile)
+	}
+
+	return last.event, nil
+}
+
+// dedupStore collapses into the last event of the Store.
+func (a *Analytics) dedupStore(last *written) (*Event, error) {
+	events, err := a.store.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(events)
+	if n == 0 || events[n-1].MessageID != last.event.MessageID {
+		a.last = nil
+		return nil, nil
+	}
+
+	increment(last.event)
+	events[n-1] = last.event
+
+	return last.event, a.replace(events)
+}
+
+// increment the "count" property of `e`.
+func increment(e *Event) {
+	e.Properties = set(e.Properties, "count", count(e)+1)
+}
+
+// count returns the "count" property of `e`, which is
+// one when it hasn't been collapsed by Dedup.
+func count(e *Event) int {
+	switch n := e.Properties["count"].(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	default:
+		return 1
+	}
+}
+
+// collapse merges identical consecutive track events of `events`,
+// as Dedup does when tracking, summing their "count" properties.
+func (a *Analytics) collapse(events []*Event) (v []*Event) {
+	for _, e := range events {
+		n := len(v)
+
+		if n > 0 && collapsible(v[n-1]) && collapsible(e) && bytes.Equal(a.dedupKey(v[n-1]), a.dedupKey(e)) {
+			v[n-1].Properties = set(v[n-1].Properties, "count", count(v[n-1])+count(e))
+			continue
+		}
+
+		v = append(v, e)
+	}
+
+	return v
+}
+
+// collapsible returns true if `e` may be collapsed by Dedup.
+func collapsible(e *Event) bool {
+	return (e.Type == "" || e.Type == TypeTrack) && e.Priority != PriorityHigh
+}
@@ -0,0 +1,67 @@
+package analytics
+
+import "testing"
+
+func TestDedup(t *testing.T) {
+	testHome(t)
+
+	for _, compress := range []bool{false, true} {
+		tr := &recordTransport{}
+		a := New(&Config{WriteKey: "k", Dir: t.TempDir(), Dedup: true, Compress: compress, Transport: tr})
+
+		for i := 0; i < 5; i++ {
+			a.Track("Poll", map[string]interface{}{"x": 1})
+		}
+
+		if n, _ := a.Size(); n != 1 {
+			t.Fatalf("expected 1 collapsed event with compress=%v, got %d", compress, n)
+		}
+
+		a.Track("Other", nil)
+		a.Track("Poll", map[string]interface{}{"x": 1})
+
+		if err := a.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(tr.events) != 3 {
+			t.Fatalf("expected 3 events, got %d", len(tr.events))
+		}
+
+		if c, _ := tr.events[0].Properties["count"].(float64); c != 5 {
+			t.Fatalf("expected a count of 5, got %v", tr.events[0].Properties)
+		}
+
+		if c, ok := tr.events[2].Properties["count"]; ok {
+			t.Fatalf("expected no count on a single event, got %v", c)
+		}
+	}
+}
+
+func TestDedup_injected(t *testing.T) {
+	testHome(t)
+	tr := &recordTransport{}
+	a := New(&Config{WriteKey: "k", Dir: ".dedup", Dedup: true, TrackPreviousEvent: true, SequenceProperty: "seq", Transport: tr})
+
+	a.Track("Start", nil)
+
+	for i := 0; i < 3; i++ {
+		a.Track("Poll", map[string]interface{}{"x": 1})
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.events) != 2 {
+		t.Fatalf("expected the identical events collapsed, got %d", len(tr.events))
+	}
+
+	if c, _ := tr.events[1].Properties["count"].(float64); c != 3 {
+		t.Fatalf("expected a count of 3, got %v", tr.events[1].Properties)
+	}
+
+	if tr.events[1].Properties["previous_event"] != "Start" {
+		t.Fatalf("expected the previous event kept, got %v", tr.events[1].Properties)
+	}
+}
@@ -0,0 +1,26 @@
+package analytics
+
+import "testing"
+
+func TestDeleteEvents(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".delete"})
+
+	a.Track("a", map[string]interface{}{"email": "tj@example.com"})
+	a.Track("b", nil)
+	a.Track("c", map[string]interface{}{"email": "tj@example.com"})
+
+	n, err := a.DeleteEvents(func(e *Event) bool {
+		return e.Properties["email"] == "tj@example.com"
+	})
+
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 deleted, got %d and %v", n, err)
+	}
+
+	events, _ := New(&Config{WriteKey: "k", Dir: ".delete"}).Events()
+
+	if len(events) != 1 || events[0].Event != "b" {
+		t.Fatalf("expected only the unmatched event, got %v", events)
+	}
+}
@@ -0,0 +1,30 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDir(t *testing.T) {
+	home := testHome(t)
+	abs := filepath.Join(t.TempDir(), "state")
+
+	cases := map[string]string{
+		abs:        abs,
+		".rel":     filepath.Join(home, ".rel"),
+		"~/.tilde": filepath.Join(home, ".tilde"),
+	}
+
+	for dir, want := range cases {
+		a := New(&Config{WriteKey: "k", Dir: dir})
+
+		if a.root != want {
+			t.Fatalf("expected %q to resolve to %q, got %q", dir, want, a.root)
+		}
+
+		if _, err := os.Stat(filepath.Join(want, "id")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
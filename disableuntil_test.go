@@ -0,0 +1,38 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDisableUntil(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".until"})
+
+	if err := a.DisableUntil(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := a.Enabled(); ok {
+		t.Fatal("expected disabled before the expiry")
+	}
+}
+
+func TestDisableUntil_expired(t *testing.T) {
+	home := testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".until"})
+
+	if err := a.DisableUntil(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := a.Enabled(); !ok {
+		t.Fatal("expected enabled after the expiry")
+	}
+
+	if _, err := os.Stat(filepath.Join(home, ".until", "disable")); !os.IsNotExist(err) {
+		t.Fatalf("expected the disable file removed, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDoNotTrack(t *testing.T) {
+	home := testHome(t)
+	t.Setenv("DO_NOT_TRACK", "TRUE")
+
+	a := New(&Config{WriteKey: "k", Dir: ".dnt"})
+
+	if err := a.Track("x", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(home, ".dnt")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written, got %v", err)
+	}
+
+	if c := a.EffectiveConfig(); c.Enabled || !c.DisabledByEnv || c.DisabledByFile {
+		t.Fatalf("expected disabled by env, got %+v", c)
+	}
+
+	t.Setenv("DO_NOT_TRACK", "0")
+
+	if ok, _ := a.Enabled(); !ok {
+		t.Fatal("expected enabled when DO_NOT_TRACK is 0")
+	}
+}
@@ -0,0 +1,42 @@
+package analytics
+
+import "testing"
+
+func TestDrain(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+
+	a := New(&Config{WriteKey: "k", Dir: ".drain", Transport: tr, MinEventsToFlush: 10})
+	a.Track("x", nil)
+
+	if err := a.Drain(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.n != 1 {
+		t.Fatalf("expected the event flushed despite MinEventsToFlush, got %d", tr.n)
+	}
+
+	if ok, _ := a.Enabled(); ok {
+		t.Fatal("expected tracking disabled")
+	}
+}
+
+func TestDrain_error(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".drain", Transport: failTransport{}})
+	a.Track("x", nil)
+
+	if err := a.Drain(); err == nil {
+		t.Fatal("expected a flush error")
+	}
+
+	if ok, _ := a.Enabled(); !ok {
+		t.Fatal("expected tracking still enabled")
+	}
+
+	if n, _ := New(&Config{WriteKey: "k", Dir: ".drain"}).Size(); n != 1 {
+		t.Fatalf("expected the event kept, got %d", n)
+	}
+}
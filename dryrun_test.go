@@ -0,0 +1,52 @@
+package analytics
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/apex/log"
+)
+
+// dryRunHandler records "dry run" log entries.
+type dryRunHandler struct {
+	mu      sync.Mutex
+	entries []*log.Entry
+}
+
+func (h *dryRunHandler) HandleLog(e *log.Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e.Message == "dry run" {
+		h.entries = append(h.entries, e)
+	}
+	return nil
+}
+
+func TestDryRun(t *testing.T) {
+	testHome(t)
+	h := &dryRunHandler{}
+	l := &log.Logger{Handler: h, Level: log.InfoLevel}
+
+	a := New(&Config{WriteKey: "k", Dir: ".dryrun", Log: l, DryRun: true, DryRunKeep: true, Endpoint: "http://127.0.0.1:1"})
+	a.Track("x", map[string]interface{}{"a": 1})
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(h.entries) != 1 || h.entries[0].Fields["event"] != "x" || h.entries[0].Fields["anonymous_id"] == "" {
+		t.Fatalf("expected the event logged, got %v", h.entries)
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".dryrun", Log: l, DryRun: true})
+
+	if events, _ := b.Events(); len(events) != 1 {
+		t.Fatalf("expected DryRunKeep to retain the event, got %d", len(events))
+	}
+
+	b.Flush()
+
+	if events, _ := New(&Config{WriteKey: "k", Dir: ".dryrun", Log: l}).Events(); len(events) != 0 {
+		t.Fatalf("expected the event cleared, got %d", len(events))
+	}
+}
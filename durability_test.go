@@ -0,0 +1,53 @@
+package analytics
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDurability(t *testing.T) {
+	testHome(t)
+
+	var syncs int
+	syncFile = func(f *os.File) error {
+		syncs++
+		return f.Sync()
+	}
+	defer func() { syncFile = func(f *os.File) error { return f.Sync() } }()
+
+	cases := []struct {
+		level Durability
+		track int
+	}{
+		{DurabilityNone, 0},
+		{DurabilityOnFlush, 0},
+		{DurabilityOnEveryWrite, 3},
+	}
+
+	for _, c := range cases {
+		a := New(&Config{WriteKey: "k", Dir: t.TempDir(), Transport: &countTransport{}, Durability: c.level})
+		syncs = 0
+
+		for i := 0; i < 3; i++ {
+			a.Track("x", nil)
+		}
+
+		if syncs != c.track {
+			t.Fatalf("expected %d syncs on Track for level %d, got %d", c.track, c.level, syncs)
+		}
+
+		syncs = 0
+
+		if err := a.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if c.level == DurabilityNone && syncs != 0 {
+			t.Fatalf("expected no syncs on Flush, got %d", syncs)
+		}
+
+		if c.level != DurabilityNone && syncs == 0 {
+			t.Fatalf("expected syncs on Flush for level %d", c.level)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package analytics
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEachEvent(t *testing.T) {
+	testHome(t)
+
+	for _, strict := range []bool{false, true} {
+		a := New(&Config{WriteKey: "k", Dir: t.TempDir(), StrictDecode: strict, MaxBytes: 200})
+
+		for i := 0; i < 10; i++ {
+			a.Track("e", map[string]interface{}{"i": i})
+		}
+
+		var seen []float64
+
+		err := a.EachEvent(func(e *Event) error {
+			seen = append(seen, e.Properties["i"].(float64))
+			return nil
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(seen) != 10 {
+			t.Fatalf("expected 10 events across rotated files, got %d", len(seen))
+		}
+
+		for i, v := range seen {
+			if v != float64(i) {
+				t.Fatalf("expected events in order, got %v", seen)
+			}
+		}
+	}
+}
+
+func TestEachEvent_stop(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".each"})
+
+	for i := 0; i < 10; i++ {
+		a.Track("e", nil)
+	}
+
+	stop := errors.New("stop")
+	n := 0
+
+	err := a.EachEvent(func(e *Event) error {
+		n++
+		if n == 3 {
+			return stop
+		}
+		return nil
+	})
+
+	if err != stop || n != 3 {
+		t.Fatalf("expected to stop after 3 events, got %d and %v", n, err)
+	}
+
+	if s, _ := a.Size(); s != 10 {
+		t.Fatalf("expected the buffer unchanged, got %d", s)
+	}
+}
+
+func TestEachEvent_store(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".each", Store: NewMemStore()})
+	a.Track("a", nil)
+	a.Track("b", nil)
+
+	var names []string
+
+	a.EachEvent(func(e *Event) error {
+		names = append(names, e.Event)
+		return nil
+	})
+
+	if len(names) != 2 || names[0] != "a" {
+		t.Fatalf("expected the stored events, got %v", names)
+	}
+}
@@ -0,0 +1,26 @@
+package analytics
+
+import "testing"
+
+func TestEffectiveConfig(t *testing.T) {
+	testHome(t)
+	t.Setenv("DO_NOT_TRACK", "")
+
+	a := New(&Config{WriteKey: "k", Dir: ".ec"})
+	c := a.EffectiveConfig()
+
+	if !c.Enabled || c.DryRun {
+		t.Fatalf("expected enabled without dry run, got %+v", c)
+	}
+
+	if c.Endpoint != "https://api.segment.io" {
+		t.Fatalf("expected the Segment API, got %q", c.Endpoint)
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".ec", DryRun: true, Endpoint: "https://example.com"})
+	c = b.EffectiveConfig()
+
+	if !c.DryRun || c.Endpoint != "https://example.com" {
+		t.Fatalf("expected the dry run and endpoint, got %+v", c)
+	}
+}
@@ -0,0 +1,31 @@
+package analytics
+
+import "testing"
+
+func TestEnableDisable(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".en"})
+
+	steps := []struct {
+		name    string
+		fn      func() (bool, error)
+		changed bool
+	}{
+		{"enable", a.Enable, false},
+		{"disable", a.Disable, true},
+		{"disable", a.Disable, false},
+		{"enable", a.Enable, true},
+		{"enable", a.Enable, false},
+	}
+
+	for i, s := range steps {
+		changed, err := s.fn()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if changed != s.changed {
+			t.Fatalf("step %d: expected %s to report %v, got %v", i, s.name, s.changed, changed)
+		}
+	}
+}
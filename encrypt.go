@@ -0,0 +1,123 @@
+package analytics
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// newAEAD returns an AES-256-GCM cipher for `key`, or nil when no key is set.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+
+	if len(key) != 32 {
+		return nil, errors.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encryptWriter writes each Write, a record or a compressed record, as a
+// line holding the base64 encoded nonce and sealed bytes.
+type encryptWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+}
+
+// Write implementation.
+func (e encryptWriter) Write(p []byte) (int, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, errors.Wrap(err, "generating nonce")
+	}
+
+	sealed := e.aead.Seal(nonce, nonce, p, nil)
+	line := make([]byte, base64.StdEncoding.EncodedLen(len(sealed))+1)
+	base64.StdEncoding.Encode(line, sealed)
+	line[len(line)-1] = '\n'
+
+	if _, err := e.w.Write(line); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// decryptReader opens the encrypted lines of `r`. Plaintext JSON lines,
+// such as those written before EncryptionKey was set, are passed through.
+type decryptReader struct {
+	r    *bufio.Reader
+	aead cipher.AEAD
+	cur  *bytes.Reader
+}
+
+// Read implementation.
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for d.cur == nil || d.cur.Len() == 0 {
+		line, err := d.r.ReadBytes('\n')
+		if len(line) == 0 {
+			return 0, err
+		}
+
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		b, oerr := d.open(line)
+
+		// a final line without a newline was cut short mid-write
+		if oerr != nil && err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		if oerr != nil {
+			return 0, oerr
+		}
+
+		d.cur = bytes.NewReader(b)
+	}
+
+	return d.cur.Read(p)
+}
+
+// open returns the plaintext of `line`.
+func (d *decryptReader) open(line []byte) ([]byte, error) {
+	s := bytes.TrimSpace(line)
+
+	if len(s) == 0 || s[0] == '{' {
+		return line, nil
+	}
+
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(s)))
+	n, err := base64.StdEncoding.Decode(sealed, s)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding")
+	}
+	sealed = sealed[:n]
+
+	size := d.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("decrypting: record too short")
+	}
+
+	b, err := d.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "decrypting")
+	}
+
+	return b, nil
+}
@@ -0,0 +1,127 @@
+package analytics
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptionKey(t *testing.T) {
+	home := testHome(t)
+	key := bytes.Repeat([]byte("k"), 32)
+	var out bytes.Buffer
+
+	a := New(&Config{WriteKey: "k", Dir: ".enc", Output: &out})
+	a.Track("plain", nil)
+	a.Close()
+
+	for _, compress := range []bool{false, true} {
+		a = New(&Config{WriteKey: "k", Dir: ".enc", EncryptionKey: key, Compress: compress, Output: &out})
+		a.Track("secret", map[string]interface{}{"path": "/x"})
+		a.Close()
+	}
+
+	b, _ := ioutil.ReadFile(filepath.Join(home, ".enc", "events"))
+
+	if bytes.Contains(b, []byte("secret")) {
+		t.Fatal("expected the events to be encrypted")
+	}
+
+	a = New(&Config{WriteKey: "k", Dir: ".enc", EncryptionKey: key, Output: &out})
+
+	events, err := a.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 3 || events[0].Event != "plain" || events[2].Event != "secret" {
+		t.Fatalf("expected the plaintext and encrypted events, got %v", events)
+	}
+
+	a.Close()
+
+	if err := a.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncryptionKey_wrongKey(t *testing.T) {
+	testHome(t)
+	var out bytes.Buffer
+
+	a := New(&Config{WriteKey: "k", Dir: ".enc", EncryptionKey: bytes.Repeat([]byte("k"), 32)})
+	a.Track("secret", nil)
+	a.Close()
+
+	a = New(&Config{WriteKey: "k", Dir: ".enc", EncryptionKey: bytes.Repeat([]byte("x"), 32), Output: &out})
+
+	if _, err := a.Events(); err == nil {
+		t.Fatal("expected an error reading with the wrong key")
+	}
+
+	if err := a.Flush(); err == nil {
+		t.Fatal("expected an error flushing with the wrong key")
+	}
+
+	a = New(&Config{WriteKey: "k", Dir: ".enc", EncryptionKey: bytes.Repeat([]byte("k"), 32), Output: &out})
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(out.Bytes(), []byte("secret")) {
+		t.Fatalf("expected the events kept for the right key, got %s", out.String())
+	}
+}
+
+func TestEncryptionKey_invalid(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".enc", EncryptionKey: []byte("short")})
+
+	if err := a.Validate(); err == nil {
+		t.Fatal("expected an error for a short key")
+	}
+
+	if err := a.Track("x", nil); err == nil {
+		t.Fatal("expected Track to fail")
+	}
+}
+
+func TestEncryptionKey_compress(t *testing.T) {
+	home := testHome(t)
+	key := bytes.Repeat([]byte("k"), 32)
+	props := map[string]interface{}{"output": strings.Repeat("compressible ", 200)}
+	size := make(map[bool]int64)
+
+	for _, compress := range []bool{false, true} {
+		dir := fmt.Sprintf(".enc%v", compress)
+		a := New(&Config{WriteKey: "k", Dir: dir, EncryptionKey: key, Compress: compress})
+
+		for i := 0; i < 10; i++ {
+			a.Track("run", props)
+		}
+
+		a.Close()
+
+		info, err := os.Stat(filepath.Join(home, dir, "events"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		size[compress] = info.Size()
+
+		b := New(&Config{WriteKey: "k", Dir: dir, EncryptionKey: key, Compress: compress})
+
+		if events, err := b.Events(); err != nil || len(events) != 10 || events[9].Properties["output"] != props["output"] {
+			t.Fatalf("expected the events read back with compress=%v, got %d and %v", compress, len(events), err)
+		}
+	}
+
+	if size[true]*4 > size[false] {
+		t.Fatalf("expected compression before encryption to shrink the events, got %d and %d bytes", size[true], size[false])
+	}
+}
@@ -0,0 +1,39 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestEndpoint(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".ep", Endpoint: s.URL})
+	a.Track("x", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.Messages()); n != 1 {
+		t.Fatalf("expected 1 message, got %d", n)
+	}
+}
+
+func TestEndpoint_invalid(t *testing.T) {
+	testHome(t)
+	ch := make(chan LifecycleEvent, 4)
+
+	a := New(&Config{WriteKey: "k", Dir: ".ep", Endpoint: "api.example.com", Lifecycle: ch})
+
+	if e := <-ch; e.State != StateError || e.Err == nil {
+		t.Fatalf("expected a StateError, got %+v", e)
+	}
+
+	if err := a.Track("x", nil); err == nil {
+		t.Fatal("expected Track to fail")
+	}
+}
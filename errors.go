@@ -0,0 +1,66 @@
+package analytics
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrFlushDelivery is matched by errors.Is for flush errors caused by
+// delivery, such as a network error or an unexpected response, which
+// may be retried later.
+var ErrFlushDelivery = errors.New("flush delivery failed")
+
+// ErrStorage is matched by errors.Is for errors caused by reading
+// or writing the buffer in ~/<dir>.
+var ErrStorage = errors.New("storage failed")
+
+// kindError categorizes an error as `kind`, keeping the message
+// of the cause, which remains available to errors.Is and errors.As.
+type kindError struct {
+	kind error
+	err  error
+}
+
+// Error implementation.
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the cause.
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// Is returns true when `target` is the kind of error.
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// deliveryError returns `err` categorized as ErrFlushDelivery. The
+// errors of a done context are returned as-is, see FlushContext().
+func deliveryError(err error) error {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return err
+	}
+
+	if errors.Is(err, ErrFlushDelivery) {
+		return err
+	}
+
+	return &kindError{kind: ErrFlushDelivery, err: err}
+}
+
+// storageError returns `err` categorized as ErrStorage, unless
+// it's already categorized.
+func storageError(err error) error {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return err
+	}
+
+	if errors.Is(err, ErrFlushDelivery) || errors.Is(err, ErrStorage) {
+		return err
+	}
+
+	return &kindError{kind: ErrStorage, err: err}
+}
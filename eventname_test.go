@@ -0,0 +1,45 @@
+package analytics
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTrack_emptyName(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".name"})
+
+	if err := a.Track("", nil); err != ErrEmptyEventName {
+		t.Fatalf("expected ErrEmptyEventName, got %v", err)
+	}
+
+	if n, _ := a.Size(); n != 0 {
+		t.Fatalf("expected nothing buffered, got %d", n)
+	}
+}
+
+func TestTrack_validateEventName(t *testing.T) {
+	testHome(t)
+	lower := errors.New("lowercase event name")
+
+	a := New(&Config{WriteKey: "k", Dir: ".name", ValidateEventName: func(name string) error {
+		if name == "file opened" {
+			return lower
+		}
+		return nil
+	}})
+
+	if err := a.Track("file opened", nil); err == nil {
+		t.Fatal("expected the name to be rejected")
+	}
+
+	if err := a.Track("File Opened", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := a.Events()
+
+	if len(events) != 1 || events[0].Event != "File Opened" {
+		t.Fatalf("expected only the valid event, got %v", events)
+	}
+}
@@ -0,0 +1,74 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventTTL(t *testing.T) {
+	testHome(t)
+	now := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+	tr := &recordTransport{}
+	var reasons []error
+
+	a := New(&Config{
+		WriteKey:  "k",
+		Dir:       ".ttl",
+		Transport: tr,
+		Clock:     &fakeClock{t: now},
+		EventTTL:  24 * time.Hour,
+		OnDrop:    func(e *Event, err error) { reasons = append(reasons, err) },
+	})
+
+	a.TrackAt("stale", nil, now.Add(-30*24*time.Hour))
+	a.TrackAt("fresh", nil, now.Add(-time.Hour))
+
+	events, _ := a.Events()
+
+	if len(events) != 1 || events[0].Event != "fresh" {
+		t.Fatalf("expected only the fresh event listed, got %v", events)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.events) != 1 || tr.events[0].Event != "fresh" {
+		t.Fatalf("expected only the fresh event sent, got %v", tr.events)
+	}
+
+	if len(reasons) != 1 || reasons[0] != ErrEventExpired {
+		t.Fatalf("expected the stale event dropped as expired, got %v", reasons)
+	}
+}
+
+func TestEventTTL_compact(t *testing.T) {
+	testHome(t)
+	now := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	a := New(&Config{WriteKey: "k", Dir: ".ttl", Clock: &fakeClock{t: now}, EventTTL: time.Hour})
+	a.TrackAt("stale", nil, now.Add(-2*time.Hour))
+	a.Track("fresh", nil)
+
+	if err := a.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := New(&Config{WriteKey: "k", Dir: ".ttl"}).Events()
+
+	if len(events) != 1 || events[0].Event != "fresh" {
+		t.Fatalf("expected the stale event removed, got %v", events)
+	}
+}
+
+func TestEventTTL_zero(t *testing.T) {
+	testHome(t)
+	now := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	a := New(&Config{WriteKey: "k", Dir: ".ttl", Clock: &fakeClock{t: now}})
+	a.TrackAt("old", nil, now.Add(-365*24*time.Hour))
+
+	if events, _ := a.Events(); len(events) != 1 {
+		t.Fatalf("expected events to never expire, got %v", events)
+	}
+}
@@ -0,0 +1,90 @@
+package analytics
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ExportVersion is the version of the Export format.
+const ExportVersion = 1
+
+// export is the format written by Export.
+type export struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Events     []*Event  `json:"events"`
+}
+
+// Export writes the buffered events, including high priority events, to `w`
+// as a versioned JSON document which may be restored with Import, for
+// example on another machine. The buffer is left unchanged.
+func (a *Analytics) Export(w io.Writer) error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	events, err := a.readEvents()
+	if err != nil {
+		return storageError(errors.Wrap(err, "reading events"))
+	}
+
+	high, err := a.priorityEvents()
+	if err != nil {
+		return storageError(errors.Wrap(err, "reading priority events"))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(&export{
+		Version:    ExportVersion,
+		ExportedAt: a.Clock.Now(),
+		Events:     append(high, events...),
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "encoding")
+	}
+
+	return nil
+}
+
+// Import appends the events written by Export from `r` to the buffer, in
+// order, keeping their timestamps and message ids. This method is a no-op
+// when tracking is disabled.
+func (a *Analytics) Import(r io.Reader) error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	if a.disabled() {
+		return nil
+	}
+
+	var v export
+
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return errors.Wrap(err, "decoding")
+	}
+
+	if v.Version != ExportVersion {
+		return errors.Errorf("unsupported export version %d", v.Version)
+	}
+
+	for _, e := range v.Events {
+		if err := a.write(e); err != nil {
+			return storageError(errors.Wrap(err, "writing"))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package analytics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".export"})
+	a.Track("a", map[string]interface{}{"n": 1})
+	a.Track("b", map[string]interface{}{"s": "x"})
+	a.Track("c", nil)
+
+	var buf bytes.Buffer
+
+	if err := a.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".import"})
+
+	if err := b.Import(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	orig, _ := a.Events()
+	events, _ := b.Events()
+
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+
+	for i, e := range events {
+		if e.Event != orig[i].Event || e.MessageID != orig[i].MessageID || !e.Timestamp.Equal(orig[i].Timestamp) {
+			t.Fatalf("expected %+v, got %+v", orig[i], e)
+		}
+	}
+
+	if events[1].Properties["s"] != "x" {
+		t.Fatalf("expected the properties kept, got %v", events[1].Properties)
+	}
+}
+
+func TestImport_version(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".import"})
+
+	if err := a.Import(strings.NewReader(`{"version":9}`)); err == nil {
+		t.Fatal("expected an unsupported version error")
+	}
+}
@@ -0,0 +1,35 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestFallbackInMemory(t *testing.T) {
+	t.Setenv("HOME", "/proc")
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	var got error
+	a := New(&Config{WriteKey: "k", Dir: ".fallback", Endpoint: s.URL, FallbackInMemory: true, OnStorageUnavailable: func(err error) { got = err }})
+
+	if got == nil {
+		t.Fatal("expected OnStorageUnavailable to be called")
+	}
+
+	a.Track("A", nil)
+	a.Track("B", nil)
+
+	if n, err := a.Size(); err != nil || n != 2 {
+		t.Fatalf("expected 2 events in memory, got %d and %v", n, err)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.Messages()); n != 2 {
+		t.Fatalf("expected 2 messages, got %d", n)
+	}
+}
@@ -0,0 +1,49 @@
+package analytics
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileTransport(t *testing.T) {
+	home := testHome(t)
+	path := filepath.Join(home, "out.jsonl")
+
+	a := New(&Config{WriteKey: "k", Dir: ".file", Transport: NewFileTransport(path)})
+	a.Track("a", map[string]interface{}{"n": 1})
+	a.Track("b", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var lines []Line
+	s := bufio.NewScanner(f)
+
+	for s.Scan() {
+		var l Line
+
+		if err := json.Unmarshal(s.Bytes(), &l); err != nil {
+			t.Fatal(err)
+		}
+
+		lines = append(lines, l)
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	if l := lines[0]; l.Event != "a" || l.Type != "track" || l.UserID != a.anonymousID {
+		t.Fatalf("expected a track line for the anonymous id, got %+v", l)
+	}
+}
@@ -0,0 +1,25 @@
+package analytics
+
+import "testing"
+
+func TestTrackFirstRun(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".firstrun", TrackFirstRun: true})
+
+	if !a.FirstRun() {
+		t.Fatal("expected the first run on a clean dir")
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".firstrun", TrackFirstRun: true})
+
+	if b.FirstRun() {
+		t.Fatal("expected a subsequent run")
+	}
+
+	events, _ := b.Events()
+
+	if len(events) != 1 || events[0].Event != "first_run" {
+		t.Fatalf("expected a single first_run event, got %v", events)
+	}
+}
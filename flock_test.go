@@ -0,0 +1,82 @@
+package analytics
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestFlock(t *testing.T) {
+	testHome(t)
+	var out bytes.Buffer
+
+	a := New(&Config{WriteKey: "k", Dir: ".flock"})
+	b := New(&Config{WriteKey: "k", Dir: ".flock", Output: &out})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	done := make(chan struct{})
+
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < 300; i++ {
+			if err := a.Track("x", nil); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if err := b.Flush(); err != nil {
+				t.Error(err)
+			}
+
+			b.Reopen()
+		}
+	}()
+
+	wg.Wait()
+	b.Flush()
+	a.Close()
+
+	c := New(&Config{WriteKey: "k", Dir: ".flock"})
+	events, _ := c.Events()
+	flushed := bytes.Count(out.Bytes(), []byte("\n"))
+
+	if flushed+len(events) != 300 {
+		t.Fatalf("expected 300 events flushed or buffered, got %d and %d", flushed, len(events))
+	}
+}
+
+func TestFlock_flushInProgress(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+	b := New(&Config{WriteKey: "k", Dir: ".flock", Transport: tr})
+
+	a := New(&Config{
+		WriteKey: "k",
+		Dir:      ".flock",
+		Transport: TransportFunc(func(_ string, events []*Event) error {
+			return b.Flush()
+		}),
+	})
+
+	a.Track("x", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.sends != 0 {
+		t.Fatalf("expected the concurrent flush to be skipped, got %d sends", tr.sends)
+	}
+}
@@ -0,0 +1,34 @@
+package analytics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditionalFlushBytes(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+	a := New(&Config{WriteKey: "k", Dir: ".fb", Transport: tr})
+	a.Touch()
+
+	big := strings.Repeat("x", 2000)
+	a.Track("a", map[string]interface{}{"big": big})
+	a.Track("b", map[string]interface{}{"big": big})
+
+	if err := a.ConditionalFlushBytes(100, 1<<20, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.n != 0 {
+		t.Fatalf("expected no flush under the byte limit, got %d", tr.n)
+	}
+
+	if err := a.ConditionalFlushBytes(100, 4000, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.n != 2 {
+		t.Fatalf("expected a flush over the byte limit, got %d", tr.n)
+	}
+}
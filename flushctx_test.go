@@ -0,0 +1,42 @@
+package analytics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFlushContext(t *testing.T) {
+	testHome(t)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(300 * time.Millisecond):
+		}
+	}))
+	defer s.Close()
+
+	for _, direct := range []bool{false, true} {
+		a := New(&Config{WriteKey: "k", Dir: t.TempDir(), Endpoint: s.URL, DirectHTTP: direct, FlushRetries: 3})
+		a.Track("x", nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		start := time.Now()
+
+		if err := a.FlushContext(ctx); err != context.Canceled {
+			t.Fatalf("expected context.Canceled with direct=%v, got %v", direct, err)
+		}
+
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("expected cancellation to stop retries, took %s", d)
+		}
+
+		if n, _ := a.Size(); n != 1 {
+			t.Fatalf("expected the event kept, got %d", n)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package analytics
+
+import (
+	"sync"
+	"time"
+)
+
+// StartFlusher starts a goroutine calling ConditionalFlush(size, interval)
+// every `interval`, reopening the events file afterwards so that tracking
+// continues. The returned stop func halts the flusher and flushes once more,
+// closing the tracker. It's safe to call stop more than once.
+func (a *Analytics) StartFlusher(size int, interval time.Duration) (stop func()) {
+	if a.noop {
+		return func() {}
+	}
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				a.tick(size, interval)
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			close(quit)
+			<-done
+
+			if err := a.Flush(); err != nil && err != ErrFlushPaused && err != ErrOffline && err != ErrFlushThrottled {
+				a.Log.WithError(err).Error("final flush")
+			}
+		})
+	}
+}
+
+// tick performs a scheduled ConditionalFlush.
+func (a *Analytics) tick(size int, interval time.Duration) {
+	a.mu.Lock()
+	defer a.unlock()
+
+	if a.disabled() {
+		return
+	}
+
+	err := a.conditionalFlush(size, 0, interval)
+	if err != nil && err != ErrFlushPaused && err != ErrOffline && err != ErrFlushThrottled {
+		a.Log.WithError(err).Error("scheduled flush")
+	}
+
+	if a.store == nil {
+		a.initEvents()
+	}
+}
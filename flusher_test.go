@@ -0,0 +1,35 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartFlusher(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+	a := New(&Config{WriteKey: "k", Dir: ".flusher", Transport: tr})
+	stop := a.StartFlusher(3, 20*time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		a.Track("x", nil)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	tr.mu.Lock()
+	n := tr.n
+	tr.mu.Unlock()
+
+	if n != 10 {
+		t.Fatalf("expected 10 events flushed in the background, got %d", n)
+	}
+
+	a.Track("y", nil)
+	stop()
+	stop()
+
+	if tr.n != 11 {
+		t.Fatalf("expected a final flush on stop, got %d", tr.n)
+	}
+}
@@ -0,0 +1,30 @@
+package analytics
+
+import "testing"
+
+func TestFlushFunc(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+	a := New(&Config{WriteKey: "k", Dir: ".ff", Transport: tr})
+
+	a.Track("build", nil)
+	a.Track("crash", nil)
+	a.Track("build", nil)
+	a.Track("crash", nil)
+
+	n, err := a.FlushFunc(func(e *Event) bool { return e.Event == "crash" })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 || tr.n != 2 {
+		t.Fatalf("expected 2 events delivered, got %d and %d", n, tr.n)
+	}
+
+	a.Track("deploy", nil)
+	events, _ := a.Events()
+
+	if len(events) != 3 || events[0].Event != "build" || events[2].Event != "deploy" {
+		t.Fatalf("expected the unmatched events kept, got %v", events)
+	}
+}
@@ -0,0 +1,33 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestFlushN(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".fn", Endpoint: s.URL})
+	a.Identify(map[string]interface{}{"plan": "pro"})
+
+	for i := 0; i < 3; i++ {
+		a.Track("x", nil)
+	}
+
+	n, err := a.FlushN()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 3 {
+		t.Fatalf("expected 3 events excluding the identify call, got %d", n)
+	}
+
+	if m := len(s.Messages()); m != 4 {
+		t.Fatalf("expected 4 messages, got %d", m)
+	}
+}
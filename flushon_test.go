@@ -0,0 +1,30 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestFlushOnEvents(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".flushon", Endpoint: s.URL, FlushOnEvents: []string{"Now"}})
+	a.Track("A", nil)
+
+	if err := a.Track("Now", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	a.Track("B", nil)
+
+	if n := len(s.Messages()); n != 2 {
+		t.Fatalf("expected 2 messages flushed, got %d", n)
+	}
+
+	if n, _ := a.Size(); n != 1 {
+		t.Fatalf("expected 1 event buffered, got %d", n)
+	}
+}
@@ -0,0 +1,60 @@
+package analytics
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowTransport sends slowly.
+type slowTransport struct {
+	mu   sync.Mutex
+	sent int
+}
+
+func (s *slowTransport) Send(id string, events []*Event) error {
+	time.Sleep(300 * time.Millisecond)
+	s.mu.Lock()
+	s.sent += len(events)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *slowTransport) Close() error { return nil }
+
+func TestFlushTimeout(t *testing.T) {
+	testHome(t)
+
+	for _, mode := range []ClearMode{ClearTruncate, ClearRemove} {
+		dir := t.TempDir()
+		a := New(&Config{WriteKey: "k", Dir: dir, Transport: &slowTransport{}, FlushClearMode: mode})
+		a.Track("x", nil)
+		a.Track("y", nil)
+		start := time.Now()
+
+		if err := a.FlushTimeout(50 * time.Millisecond); err != ErrFlushTimeout {
+			t.Fatalf("expected ErrFlushTimeout, got %v", err)
+		}
+
+		if d := time.Since(start); d > 200*time.Millisecond {
+			t.Fatalf("expected to return at the timeout, took %s", d)
+		}
+
+		time.Sleep(400 * time.Millisecond)
+
+		if n, _ := a.Size(); n != 2 {
+			t.Fatalf("expected the events kept after the timeout, got %d", n)
+		}
+
+		tr := &countTransport{}
+		b := New(&Config{WriteKey: "k", Dir: dir, Transport: tr, FlushClearMode: mode})
+
+		if err := b.FlushTimeout(time.Second); err != nil {
+			t.Fatal(err)
+		}
+
+		if n, _ := b.Size(); n != 0 || tr.n != 2 {
+			t.Fatalf("expected the events flushed later, got %d left and %d sent", n, tr.n)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package analytics
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFlushTo(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".flushto"})
+	a.Track("a", nil)
+	a.Track("b", nil)
+
+	var got []*Event
+	var uid string
+
+	n, err := a.FlushTo(func(id string, events []*Event) error {
+		uid = id
+		got = events
+		return nil
+	})
+
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 flushed, got %d and %v", n, err)
+	}
+
+	if len(got) != 2 || got[0].Event != "a" || uid != a.anonymousID {
+		t.Fatalf("expected the events for the anonymous id, got %v for %q", got, uid)
+	}
+
+	if n, _ := New(&Config{WriteKey: "k", Dir: ".flushto"}).Size(); n != 0 {
+		t.Fatalf("expected the buffer cleared, got %d", n)
+	}
+}
+
+func TestFlushTo_error(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".flushto"})
+	a.Track("a", nil)
+
+	n, err := a.FlushTo(func(id string, events []*Event) error {
+		return errors.New("boom")
+	})
+
+	if err == nil || n != 0 {
+		t.Fatalf("expected an error, got %d and %v", n, err)
+	}
+
+	if a.Transport != nil {
+		t.Fatal("expected the transport restored")
+	}
+
+	if n, _ := a.Size(); n != 1 {
+		t.Fatalf("expected the event kept, got %d", n)
+	}
+}
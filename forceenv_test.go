@@ -0,0 +1,60 @@
+package analytics
+
+import "testing"
+
+func TestForceEnabledEnv(t *testing.T) {
+	testHome(t)
+	t.Setenv("DO_NOT_TRACK", "")
+	t.Setenv("MYAPP_ANALYTICS", "on")
+
+	a := New(&Config{WriteKey: "k", Dir: ".force", ForceEnabledEnv: "MYAPP_ANALYTICS", ConsentRequired: true})
+	a.Disable()
+
+	if ok, _ := a.Enabled(); !ok {
+		t.Fatal("expected forced on despite the disable file and consent")
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".force", ForceEnabledEnv: "MYAPP_ANALYTICS", ConsentRequired: true})
+	b.Track("x", nil)
+
+	if n, _ := b.Size(); n != 1 {
+		t.Fatalf("expected 1 event, got %d", n)
+	}
+
+	if c := b.EffectiveConfig(); !c.Enabled || c.Forced != "on" || !c.DisabledByFile {
+		t.Fatalf("expected forced on over the disable file, got %+v", c)
+	}
+
+	t.Setenv("DO_NOT_TRACK", "1")
+
+	if ok, _ := b.Enabled(); ok {
+		t.Fatal("expected DO_NOT_TRACK to win")
+	}
+}
+
+func TestForceEnabledEnv_off(t *testing.T) {
+	testHome(t)
+	t.Setenv("DO_NOT_TRACK", "")
+
+	a := New(&Config{WriteKey: "k", Dir: ".force", ForceEnabledEnv: "MYAPP_ANALYTICS"})
+	a.Track("x", nil)
+
+	t.Setenv("MYAPP_ANALYTICS", "OFF")
+	b := New(&Config{WriteKey: "k", Dir: ".force", ForceEnabledEnv: "MYAPP_ANALYTICS"})
+
+	if ok, _ := b.Enabled(); ok {
+		t.Fatal("expected forced off")
+	}
+
+	b.Track("y", nil)
+
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("MYAPP_ANALYTICS", "")
+
+	if n, _ := New(&Config{WriteKey: "k", Dir: ".force", ForceEnabledEnv: "MYAPP_ANALYTICS"}).Size(); n != 1 {
+		t.Fatalf("expected forced off to neither track nor flush, got %d", n)
+	}
+}
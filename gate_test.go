@@ -0,0 +1,86 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// chanGate is a Gate backed by a buffered channel.
+type chanGate chan struct{}
+
+func (g chanGate) Acquire(ctx context.Context) error {
+	select {
+	case g <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g chanGate) Release() { <-g }
+
+func TestFlushGate(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+	gate := make(chanGate, 1)
+	gate <- struct{}{}
+
+	a := New(&Config{WriteKey: "k", Dir: ".gate", Transport: tr, FlushGate: gate})
+	a.Track("x", nil)
+
+	done := make(chan error)
+
+	go func() {
+		done <- a.Flush()
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("expected Flush to wait for the gate, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	tr.mu.Lock()
+	sends := tr.sends
+	tr.mu.Unlock()
+
+	if sends != 0 {
+		t.Fatalf("expected no upload while gated, got %d", sends)
+	}
+
+	<-gate
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Flush to proceed once the gate is released")
+	}
+
+	if tr.n != 1 || len(gate) != 0 {
+		t.Fatalf("expected the upload and the gate released, got %d sent and %d held", tr.n, len(gate))
+	}
+}
+
+func TestFlushGate_context(t *testing.T) {
+	testHome(t)
+	gate := make(chanGate, 1)
+	gate <- struct{}{}
+
+	a := New(&Config{WriteKey: "k", Dir: ".gate", Transport: &countTransport{}, FlushGate: gate})
+	a.Track("x", nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := a.FlushContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline while gated, got %v", err)
+	}
+
+	if n, _ := a.Size(); n != 1 {
+		t.Fatalf("expected the event kept, got %d", n)
+	}
+}
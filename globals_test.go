@@ -0,0 +1,22 @@
+package analytics
+
+import "testing"
+
+func TestSetGlobalProperties(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".glob"})
+
+	a.SetGlobalProperties(map[string]interface{}{"ci": true, "shell": "zsh"})
+	a.Track("x", map[string]interface{}{"shell": "fish"})
+	a.Track("y", nil)
+
+	events, _ := a.Events()
+
+	if p := events[0].Properties; p["shell"] != "fish" || p["ci"] != true {
+		t.Fatalf("expected the event's properties to take precedence, got %v", p)
+	}
+
+	if p := events[1].Properties; p["shell"] != "zsh" || p["ci"] != true {
+		t.Fatalf("expected the global properties, got %v", p)
+	}
+}
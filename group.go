@@ -0,0 +1,65 @@
+package analytics
+
+import "github.com/pkg/errors"
+
+// Group associates the user with the group `id`, such as a team or
+// organization, along with the group's `traits`. The group call is
+// buffered and sent on Flush, superseding earlier calls for the same
+// group. This method is a no-op when tracking is disabled.
+func (a *Analytics) Group(id string, traits map[string]interface{}) error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	if a.disabled() {
+		return nil
+	}
+
+	if id == "" {
+		return errors.New("empty group id")
+	}
+
+	err := a.write(&Event{
+		Type:      TypeGroup,
+		GroupID:   id,
+		Traits:    a.sanitize(traits),
+		Timestamp: a.Clock.Now(),
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "buffering group")
+	}
+
+	return nil
+}
+
+// latestGroups returns `events` with only the most recent group call for
+// each group id, as the earlier calls' traits are superseded.
+func latestGroups(events []*Event) []*Event {
+	last := make(map[string]int)
+
+	for i, e := range events {
+		if e.Type == TypeGroup {
+			last[e.GroupID] = i
+		}
+	}
+
+	if len(last) == 0 {
+		return events
+	}
+
+	var v []*Event
+
+	for i, e := range events {
+		if e.Type == TypeGroup && last[e.GroupID] != i {
+			continue
+		}
+
+		v = append(v, e)
+	}
+
+	return v
+}
@@ -0,0 +1,104 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestGroup(t *testing.T) {
+	testHome(t)
+	var body string
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body += string(b)
+	}))
+	defer s.Close()
+
+	for _, direct := range []bool{false, true} {
+		body = ""
+		a := New(&Config{WriteKey: "k", Dir: ".grp", Endpoint: s.URL, DirectHTTP: direct})
+		a.Track("x", nil)
+
+		if err := a.Group("acme", map[string]interface{}{"plan": "pro"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := a.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range []string{`"type":"group"`, `"groupId":"acme"`, `"plan":"pro"`} {
+			if !strings.Contains(body, s) {
+				t.Fatalf("expected %s in %s", s, body)
+			}
+		}
+	}
+}
+
+func TestGroup_latest(t *testing.T) {
+	testHome(t)
+	var sent []*Event
+
+	a := New(&Config{
+		WriteKey: "k",
+		Dir:      ".grp",
+		Transport: TransportFunc(func(_ string, events []*Event) error {
+			sent = events
+			return nil
+		}),
+	})
+
+	a.Group("acme", map[string]interface{}{"plan": "free"})
+	a.Track("x", nil)
+	a.Group("other", map[string]interface{}{"plan": "free"})
+	a.Group("acme", map[string]interface{}{"plan": "pro"})
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(sent))
+	}
+
+	if sent[1].GroupID != "other" || sent[2].GroupID != "acme" || sent[2].Traits["plan"] != "pro" {
+		t.Fatalf("expected only the latest call per group, got %v", sent)
+	}
+
+	if n, _ := a.Size(); n != 0 {
+		t.Fatalf("expected the superseded calls to be removed, got %d", n)
+	}
+}
+
+func TestGroup_userID(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".grp", Endpoint: s.URL})
+	a.SetUserID("acct-1")
+
+	if err := a.Group("acme", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	a.Close()
+
+	b := New(&Config{WriteKey: "k", Dir: ".grp", Endpoint: s.URL})
+
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := s.Messages()
+
+	if len(m) != 1 || m[0]["type"] != "group" || m[0]["groupId"] != "acme" || m[0]["userId"] != "acct-1" {
+		t.Fatalf("expected the buffered group call with the user id, got %v", m)
+	}
+}
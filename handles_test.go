@@ -0,0 +1,83 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openEvents returns the number of open events files under dir.
+func openEvents(dir string) int {
+	fds, _ := ioutil.ReadDir("/proc/self/fd")
+	n := 0
+
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+		if err == nil && strings.HasPrefix(target, dir) && strings.Contains(target, "events") {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestReopen(t *testing.T) {
+	testHome(t)
+
+	for _, mode := range []ClearMode{ClearRemove, ClearTruncate} {
+		dir := t.TempDir()
+		a := New(&Config{WriteKey: "k", Dir: dir, Transport: &countTransport{}, FlushClearMode: mode, MaxBytes: 100})
+
+		for i := 0; i < 10; i++ {
+			a.Track("x", nil)
+		}
+
+		a.Reopen()
+
+		if err := a.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := openEvents(dir); n != 0 {
+			t.Fatalf("expected no open events files with mode %v, got %d", mode, n)
+		}
+
+		if err := a.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		a.Close()
+	}
+}
+
+func TestFlush_releasesHandle(t *testing.T) {
+	home := testHome(t)
+	dir := filepath.Join(home, ".handle")
+
+	a := New(&Config{WriteKey: "k", Dir: ".handle", Transport: &countTransport{}})
+	a.Track("x", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := openEvents(dir); n != 0 {
+		t.Fatalf("expected the events file released, got %d open", n)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "events")); !os.IsNotExist(err) {
+		t.Fatalf("expected the events file removed, got %v", err)
+	}
+
+	for _, name := range []string{"id", "disable"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %s to be removable, got %s", name, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package analytics
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEventsHeader(t *testing.T) {
+	home := testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".header", EventsHeader: true})
+	a.Track("a", nil)
+	a.Track("b", nil)
+
+	f, err := os.Open(filepath.Join(home, ".header", "events"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Scan()
+
+	var r record
+
+	if err := json.Unmarshal(s.Bytes(), &r); err != nil || r.Header == nil {
+		t.Fatalf("expected a header line, got %s", s.Bytes())
+	}
+
+	if r.Header.Version != HeaderVersion || r.Header.ID != a.anonymousID || r.Header.CreatedAt.IsZero() {
+		t.Fatalf("expected the header metadata, got %+v", r.Header)
+	}
+
+	events, err := a.Events()
+
+	if err != nil || len(events) != 2 || events[0].Event != "a" {
+		t.Fatalf("expected the header skipped, got %v and %v", events, err)
+	}
+}
+
+func TestEventsHeader_read(t *testing.T) {
+	home := testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".header"})
+	a.Close()
+
+	lines := `{"header":{"version":1,"created_at":"2020-01-01T00:00:00Z","id":"x"}}` + "\n" +
+		`{"event":"a","properties":null,"timestamp":"2020-01-01T00:00:00Z"}` + "\n"
+
+	ioutil.WriteFile(filepath.Join(home, ".header", "events"), []byte(lines), 0600)
+
+	for _, strict := range []bool{false, true} {
+		b := New(&Config{WriteKey: "k", Dir: ".header", StrictDecode: strict})
+		events, err := b.Events()
+
+		if err != nil || len(events) != 1 || events[0].Event != "a" {
+			t.Fatalf("expected the header ignored with strict=%v, got %v and %v", strict, events, err)
+		}
+
+		b.Close()
+	}
+}
@@ -0,0 +1,78 @@
+package analytics
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countTransport counts the events and sends it receives,
+// failing each send with err when set.
+type countTransport struct {
+	mu    sync.Mutex
+	n     int
+	sends int
+	err   error
+}
+
+func (c *countTransport) Send(id string, events []*Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sends++
+	if c.err != nil {
+		return c.err
+	}
+	c.n += len(events)
+	return nil
+}
+
+func (c *countTransport) Close() error { return nil }
+
+// recordTransport records the events it receives.
+type recordTransport struct {
+	events []*Event
+}
+
+func (r *recordTransport) Send(id string, events []*Event) error {
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func (r *recordTransport) Close() error { return nil }
+
+// failTransport fails every send.
+type failTransport struct{}
+
+func (failTransport) Send(string, []*Event) error { return errors.New("boom") }
+func (failTransport) Close() error                { return nil }
+
+// fakeClock is a Clock advanced by hand.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.t }
+
+// testHome sets $HOME to a temporary directory for the test, returning it.
+func testHome(t testing.TB) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	return home
+}
+
+// within fails the test when `fn` doesn't return within `d`, such as on a deadlock.
+func within(t *testing.T, d time.Duration, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("timed out after %s", d)
+	}
+}
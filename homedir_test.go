@@ -0,0 +1,47 @@
+package analytics
+
+import (
+	stderrors "errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestHomeDir_error(t *testing.T) {
+	cwd := t.TempDir()
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	wd, _ := os.Getwd()
+	os.Chdir(cwd)
+	defer os.Chdir(wd)
+
+	errNoHome := stderrors.New("no home")
+	tr := &countTransport{}
+
+	a := New(&Config{WriteKey: "k", Dir: "~/.home", Transport: tr, HomeDir: func() (string, error) {
+		return "", errNoHome
+	}})
+
+	for _, err := range []error{
+		a.Validate(),
+		a.Track("x", nil),
+		a.Flush(),
+	} {
+		if errors.Cause(err) != errNoHome {
+			t.Fatalf("expected the home dir error, got %v", err)
+		}
+	}
+
+	if tr.sends != 0 {
+		t.Fatalf("expected nothing sent, got %d", tr.sends)
+	}
+
+	for _, dir := range []string{cwd, tmp} {
+		if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+			t.Fatalf("expected nothing written to %s, got %d files", dir, len(files))
+		}
+	}
+}
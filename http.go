@@ -0,0 +1,441 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultEndpoint is the Segment API.
+const defaultEndpoint = "https://api.segment.io"
+
+// message is a Segment API message.
+type message struct {
+	Type         string                 `json:"type"`
+	Event        string                 `json:"event,omitempty"`
+	UserID       string                 `json:"userId,omitempty"`
+	AnonymousID  string                 `json:"anonymousId,omitempty"`
+	PreviousID   string                 `json:"previousId,omitempty"`
+	Properties   map[string]interface{} `json:"properties,omitempty"`
+	Traits       map[string]interface{} `json:"traits,omitempty"`
+	GroupID      string                 `json:"groupId,omitempty"`
+	Context      map[string]interface{} `json:"context,omitempty"`
+	Integrations map[string]interface{} `json:"integrations,omitempty"`
+	Timestamp    string                 `json:"timestamp,omitempty"`
+	MessageID    string                 `json:"messageId,omitempty"`
+}
+
+// Segment's limits on the size of a batch request, and of each message.
+const (
+	maxBatchBytes   = 500 << 10
+	maxMessageBytes = 32 << 10
+)
+
+// batchOverhead is reserved for the fields of a batch around its messages.
+const batchOverhead = 1 << 10
+
+// ErrEventTooLarge is passed to OnDrop for events above Segment's 32KB message
+// limit when flushing with DirectHTTP, which are removed rather than sent.
+var ErrEventTooLarge = errors.New("event too large")
+
+// batch is a Segment API batch request.
+type batch struct {
+	Messages []json.RawMessage `json:"batch"`
+	SentAt   string            `json:"sentAt"`
+}
+
+// response is a Segment API batch response. Rejected messages are
+// listed in Errors, with the remaining messages accepted.
+type response struct {
+	Errors []struct {
+		MessageID string `json:"messageId"`
+		Message   string `json:"message"`
+	} `json:"errors"`
+}
+
+// RejectedError is returned by Flush when Segment rejected some of the
+// events. The rejected events are kept in the buffer, the rest are removed.
+type RejectedError struct {
+	IDs    []string // IDs are the message ids of the rejected events
+	Events []*Event // Events are the rejected events
+}
+
+// Is returns true for ErrFlushDelivery, as rejections are a delivery failure.
+func (e *RejectedError) Is(target error) bool {
+	return target == ErrFlushDelivery
+}
+
+// Error implementation.
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%d events rejected", len(e.IDs))
+}
+
+// PartialError is returned by Flush when a batch failed to be delivered after
+// earlier batches were. The undelivered events are kept in the buffer, and
+// only they are retried when Err is retryable.
+type PartialError struct {
+	Err    error    // Err is the error delivering the remaining batches
+	Events []*Event // Events are the undelivered events, including any rejected
+}
+
+// Is returns true for ErrFlushDelivery.
+func (e *PartialError) Is(target error) bool {
+	return target == ErrFlushDelivery
+}
+
+// Unwrap returns the delivery error.
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// Error implementation.
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d events undelivered: %s", len(e.Events), e.Err)
+}
+
+// undelivered returns the events to keep buffered when a delivery
+// fails with `err`, and true when it's a *RejectedError or *PartialError.
+func undelivered(err error) ([]*Event, bool) {
+	switch e := err.(type) {
+	case *RejectedError:
+		return e.Events, true
+	case *PartialError:
+		return e.Events, true
+	default:
+		return nil, false
+	}
+}
+
+// StatusError is returned by Flush when the Segment API responds with a
+// non-2xx status.
+type StatusError struct {
+	Code   int    // Code is the HTTP status code
+	Status string // Status is the HTTP status line
+}
+
+// Error implementation.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response %s", e.Status)
+}
+
+// Retryable returns true for statuses which may succeed when retried, server
+// errors and 429, unlike client errors such as 401 for an invalid WriteKey.
+func (e *StatusError) Retryable() bool {
+	return e.Code >= 500 || e.Code == http.StatusTooManyRequests
+}
+
+// retryable returns true when a delivery failing with `err` may be retried,
+// which excludes rejected events and responses with client error statuses,
+// including those of a *PartialError.
+func retryable(err error) bool {
+	if _, ok := err.(*RejectedError); ok {
+		return false
+	}
+
+	var serr *StatusError
+
+	if errors.As(err, &serr) {
+		return serr.Retryable()
+	}
+
+	return true
+}
+
+// sendHTTP delivers `events` to the Segment batch endpoint using net/http,
+// split into batches within Segment's size limits. An error is returned for
+// non-2xx responses, a *RejectedError when some messages were rejected, or a
+// *PartialError when a batch failed after earlier ones were delivered, so
+// that only the undelivered events are kept.
+func (a *Analytics) sendHTTP(ctx context.Context, events []*Event) error {
+	var rerr RejectedError
+
+	chunks, err := a.chunks(events)
+	if err != nil {
+		return err
+	}
+
+	for i, c := range chunks {
+		r, err := a.postBatch(ctx, c.messages)
+
+		if err != nil && i == 0 {
+			return err
+		}
+
+		if err != nil {
+			a.Log.WithError(err).WithField("batches", len(chunks)-i).Debug("error delivering remaining batches")
+			perr := &PartialError{Err: err, Events: rerr.Events}
+
+			for _, c := range chunks[i:] {
+				perr.Events = append(perr.Events, c.events...)
+			}
+
+			return perr
+		}
+
+		if err, ok := rejected(c.events, r).(*RejectedError); ok {
+			rerr.IDs = append(rerr.IDs, err.IDs...)
+			rerr.Events = append(rerr.Events, err.Events...)
+		}
+	}
+
+	if len(rerr.Events) == 0 {
+		return nil
+	}
+
+	return &rerr
+}
+
+// dropOversized returns `events` without those above maxMessageBytes,
+// which chunks would drop.
+func (a *Analytics) dropOversized(events []*Event) (v []*Event) {
+	for _, e := range events {
+		if b, err := json.Marshal(a.message(e)); err == nil && len(b) > maxMessageBytes {
+			a.drop(e, ErrEventTooLarge)
+			continue
+		}
+
+		v = append(v, e)
+	}
+
+	return v
+}
+
+// chunk is the events of a batch request, and their encoded messages.
+type chunk struct {
+	events   []*Event
+	messages []json.RawMessage
+	size     int
+}
+
+// chunks encodes `events` into batches below maxBatchBytes, dropping
+// events above maxMessageBytes as Segment would reject them.
+func (a *Analytics) chunks(events []*Event) ([]*chunk, error) {
+	var chunks []*chunk
+	c := &chunk{}
+
+	for _, e := range events {
+		b, err := json.Marshal(a.message(e))
+		if err != nil {
+			return nil, errors.Wrap(err, "marshalling")
+		}
+
+		if len(b) > maxMessageBytes {
+			a.drop(e, ErrEventTooLarge)
+			continue
+		}
+
+		if c.size+len(b)+1 > maxBatchBytes-batchOverhead {
+			chunks = append(chunks, c)
+			c = &chunk{}
+		}
+
+		c.events = append(c.events, e)
+		c.messages = append(c.messages, b)
+		c.size += len(b) + 1
+	}
+
+	if len(c.events) > 0 {
+		chunks = append(chunks, c)
+	}
+
+	return chunks, nil
+}
+
+// postBatch posts the batch of `messages`, returning
+// the response which lists any rejected messages.
+func (a *Analytics) postBatch(ctx context.Context, messages []json.RawMessage) (response, error) {
+	var r response
+
+	body, err := json.Marshal(&batch{
+		Messages: messages,
+		SentAt:   time.Now().UTC().Format(time.RFC3339),
+	})
+
+	if err != nil {
+		return r, errors.Wrap(err, "marshalling")
+	}
+
+	req, err := http.NewRequest("POST", a.batchURL(), bytes.NewReader(body))
+	if err != nil {
+		return r, errors.Wrap(err, "creating request")
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(a.WriteKey, "")
+
+	res, err := a.httpClient().Do(req)
+	if err != nil {
+		return r, errors.Wrap(err, "requesting")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return r, &StatusError{Code: res.StatusCode, Status: res.Status}
+	}
+
+	json.NewDecoder(res.Body).Decode(&r)
+	return r, nil
+}
+
+// Ping checks that Segment is reachable with the WriteKey by posting an
+// empty batch to the Endpoint, so no events are recorded. An error is
+// returned when the endpoint is unreachable or the write key is rejected.
+func (a *Analytics) Ping(ctx context.Context) error {
+	if a.noop {
+		return nil
+	}
+
+	_, err := a.postBatch(ctx, []json.RawMessage{})
+
+	if serr, ok := err.(*StatusError); ok {
+		if serr.Code == http.StatusUnauthorized || serr.Code == http.StatusForbidden {
+			return errors.Wrap(err, "invalid write key")
+		}
+	}
+
+	return err
+}
+
+// endpoint returns the Endpoint, defaulting to the Segment API.
+func (a *Analytics) endpoint() string {
+	if a.Endpoint == "" {
+		return defaultEndpoint
+	}
+
+	return a.Endpoint
+}
+
+// batchURL returns the URL of the Segment batch endpoint.
+func (a *Analytics) batchURL() string {
+	return strings.TrimSuffix(a.endpoint(), "/") + "/v1/batch"
+}
+
+// rejected returns a *RejectedError for the events of `r`.
+func rejected(events []*Event, r response) error {
+	ids := make(map[string]bool)
+
+	for _, e := range r.Errors {
+		ids[e.MessageID] = true
+	}
+
+	var err RejectedError
+
+	for _, e := range events {
+		if e.MessageID != "" && ids[e.MessageID] {
+			err.IDs = append(err.IDs, e.MessageID)
+			err.Events = append(err.Events, e)
+		}
+	}
+
+	if len(err.Events) == 0 {
+		return nil
+	}
+
+	return &err
+}
+
+// message returns the Segment message for event `e`.
+func (a *Analytics) message(e *Event) *message {
+	m := &message{
+		Type:        TypeTrack,
+		Event:       e.Event,
+		UserID:      a.userID,
+		AnonymousID: a.anonymousID,
+		Properties:  e.Properties,
+		Context:     a.context(),
+		MessageID:   e.MessageID,
+		Timestamp:   timestamp(e.Timestamp),
+	}
+
+	if e.Anonymous {
+		m.UserID = ""
+	}
+
+	if e.Type == "" || e.Type == TypeTrack {
+		m.Integrations = a.integrations(e)
+	}
+
+	if e.Type == TypeAlias {
+		m.Type = TypeAlias
+		m.UserID = e.UserID
+		m.AnonymousID = ""
+		m.PreviousID = e.PreviousID
+	}
+
+	if e.Type == TypeIdentify {
+		m.Type = TypeIdentify
+		m.Traits = e.Traits
+	}
+
+	if e.Type == TypeGroup {
+		m.Type = TypeGroup
+		m.GroupID = e.GroupID
+		m.Traits = e.Traits
+	}
+
+	return m
+}
+
+// httpClient returns a copy of the HTTPClient used for flushing,
+// observed by OnRequest when present.
+func (a *Analytics) httpClient() *http.Client {
+	c := &http.Client{}
+
+	if a.HTTPClient != nil {
+		v := *a.HTTPClient
+		c = &v
+	}
+
+	if a.OnRequest != nil {
+		c.Transport = &observer{
+			transport: c.Transport,
+			fn:        a.OnRequest,
+		}
+	}
+
+	return c
+}
+
+// observer is an http.RoundTripper which passes each request and
+// its response to a callback. The request body has already been
+// consumed, however it may be read again via Request.GetBody.
+type observer struct {
+	transport http.RoundTripper
+	fn        func(*http.Request, *http.Response, error)
+}
+
+// RoundTrip implementation.
+func (o *observer) RoundTrip(r *http.Request) (*http.Response, error) {
+	t := o.transport
+	if t == nil {
+		t = http.DefaultTransport
+	}
+
+	res, err := t.RoundTrip(r)
+	o.fn(r, res, err)
+	return res, err
+}
+
+// contextTransport is an http.RoundTripper which issues requests
+// with ctx, for clients which don't accept a context.
+type contextTransport struct {
+	transport http.RoundTripper
+	ctx       context.Context
+}
+
+// RoundTrip implementation.
+func (t *contextTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	tr := t.transport
+	if tr == nil {
+		tr = http.DefaultTransport
+	}
+
+	return tr.RoundTrip(r.WithContext(t.ctx))
+}
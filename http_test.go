@@ -0,0 +1,200 @@
+package analytics
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestDirectHTTP(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	var auth string
+
+	a := New(&Config{
+		WriteKey:   "k",
+		Dir:        ".http",
+		Endpoint:   s.URL,
+		DirectHTTP: true,
+		OnRequest: func(r *http.Request, res *http.Response, err error) {
+			auth = r.Header.Get("Authorization")
+		},
+	})
+
+	a.Track("a", map[string]interface{}{"x": 1})
+	a.Track("b", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.Messages()); n != 2 {
+		t.Fatalf("expected 2 messages, got %d", n)
+	}
+
+	if auth != "Basic azo=" {
+		t.Fatalf("expected the write key, got %q", auth)
+	}
+}
+
+func TestDirectHTTP_batchSize(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	var sizes []int64
+
+	a := New(&Config{
+		WriteKey:   "k",
+		Dir:        ".http",
+		Endpoint:   s.URL,
+		DirectHTTP: true,
+		OnRequest: func(r *http.Request, res *http.Response, err error) {
+			sizes = append(sizes, r.ContentLength)
+		},
+	})
+
+	value := strings.Repeat("x", 20<<10)
+
+	for i := 0; i < 60; i++ {
+		a.Track("big", map[string]interface{}{"value": value})
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.Messages()); n != 60 {
+		t.Fatalf("expected 60 messages, got %d", n)
+	}
+
+	if len(sizes) < 3 {
+		t.Fatalf("expected the events to be split into batches, got %v", sizes)
+	}
+
+	for _, n := range sizes {
+		if n > maxBatchBytes {
+			t.Fatalf("expected batches below %d bytes, got %d", maxBatchBytes, n)
+		}
+	}
+}
+
+func TestDirectHTTP_eventTooLarge(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	var dropped error
+
+	a := New(&Config{
+		WriteKey:   "k",
+		Dir:        ".http",
+		Endpoint:   s.URL,
+		DirectHTTP: true,
+		OnDrop:     func(e *Event, err error) { dropped = err },
+	})
+
+	a.Track("huge", map[string]interface{}{"value": strings.Repeat("x", 40<<10)})
+	a.Track("small", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !errors.Is(dropped, ErrEventTooLarge) {
+		t.Fatalf("expected ErrEventTooLarge, got %v", dropped)
+	}
+
+	if m := s.Messages(); len(m) != 1 || m[0]["event"] != "small" {
+		t.Fatalf("expected only the small event, got %v", m)
+	}
+
+	if n, _ := a.Size(); n != 0 {
+		t.Fatalf("expected the oversized event to be removed, got %d", n)
+	}
+}
+
+func TestDirectHTTP_partialFailure(t *testing.T) {
+	testHome(t)
+	var requests int
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".http", Endpoint: s.URL, DirectHTTP: true})
+	value := strings.Repeat("x", 20<<10)
+
+	for i := 0; i < 30; i++ {
+		a.Track("big", map[string]interface{}{"value": value})
+	}
+
+	delivered, rejected, err := a.FlushPartial()
+
+	var perr *PartialError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a *PartialError, got %v", err)
+	}
+
+	if len(rejected) != 0 || !errors.Is(err, ErrFlushDelivery) {
+		t.Fatalf("expected no events reported as rejected, got %d", len(rejected))
+	}
+
+	n, _ := New(&Config{WriteKey: "k", Dir: ".http", Endpoint: s.URL, DirectHTTP: true}).Size()
+
+	if n == 0 || n != len(perr.Events) || n+delivered != 30 {
+		t.Fatalf("expected only the undelivered events kept, got %d of %d", n, len(perr.Events))
+	}
+}
+
+func TestDirectHTTP_partialRetry(t *testing.T) {
+	testHome(t)
+	var requests int
+	var messages int
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 2 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		var b struct {
+			Batch []json.RawMessage `json:"batch"`
+		}
+
+		json.NewDecoder(r.Body).Decode(&b)
+		messages += len(b.Batch)
+		w.Write([]byte(`{}`))
+	}))
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".http", Endpoint: s.URL, DirectHTTP: true, FlushRetries: 1, FlushBackoff: time.Millisecond})
+	value := strings.Repeat("x", 20<<10)
+
+	for i := 0; i < 30; i++ {
+		a.Track("big", map[string]interface{}{"value": value})
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if messages != 30 {
+		t.Fatalf("expected each event delivered once, got %d", messages)
+	}
+}
@@ -0,0 +1,31 @@
+package analytics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPClient(t *testing.T) {
+	testHome(t)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer s.Close()
+
+	for _, direct := range []bool{false, true} {
+		c := &http.Client{Timeout: 20 * time.Millisecond}
+		a := New(&Config{WriteKey: "k", Dir: t.TempDir(), Endpoint: s.URL, DirectHTTP: direct, HTTPClient: c})
+		a.Track("x", nil)
+
+		if err := a.Flush(); err == nil {
+			t.Fatalf("expected a timeout error with direct=%v", direct)
+		}
+
+		if n, _ := a.Size(); n != 1 {
+			t.Fatalf("expected the event kept with direct=%v, got %d", direct, n)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package analytics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/pkg/errors"
+)
+
+// Identify associates `traits` with the user id. The most recent traits are
+// stored in ~/<dir>/traits and sent as an identify call ahead of the events
+// on every flush, so they survive flushes and are re-sent. This method is a
+// no-op when tracking is disabled.
+func (a *Analytics) Identify(traits map[string]interface{}) error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	if a.disabled() {
+		return nil
+	}
+
+	b, err := json.Marshal(a.sanitize(traits))
+	if err != nil {
+		return errors.Wrap(err, "encoding")
+	}
+
+	path := filepath.Join(a.root, "traits")
+
+	if err := ioutil.WriteFile(path, b, a.FilePerm); err != nil {
+		return errors.Wrap(err, "writing")
+	}
+
+	return nil
+}
+
+// identify returns the identify event for the stored traits, or nil when
+// Identify has not been called. An empty or corrupt traits file is ignored
+// rather than failing the flush.
+func (a *Analytics) identify() (*Event, error) {
+	path := filepath.Join(a.root, "traits")
+
+	b, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "reading")
+	}
+
+	var traits map[string]interface{}
+
+	if err := json.Unmarshal(b, &traits); err != nil {
+		a.Log.WithError(err).Debug("ignoring malformed traits")
+		return nil, nil
+	}
+
+	if traits == nil {
+		return nil, nil
+	}
+
+	id, _ := uuid.GenerateUUID()
+
+	return &Event{
+		Type:      TypeIdentify,
+		Traits:    traits,
+		Timestamp: a.Clock.Now(),
+		MessageID: id,
+	}, nil
+}
+
+// tracked returns `events` without identify events, which are
+// re-created from the stored traits on each flush.
+func tracked(events []*Event) (v []*Event) {
+	for _, e := range events {
+		if e.Type != TypeIdentify {
+			v = append(v, e)
+		}
+	}
+
+	return v
+}
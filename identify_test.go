@@ -0,0 +1,118 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestIdentify(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".id", Endpoint: s.URL})
+
+	if err := a.Identify(map[string]interface{}{"plan": "pro"}); err != nil {
+		t.Fatal(err)
+	}
+
+	a.Track("x", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	a = New(&Config{WriteKey: "k", Dir: ".id", Endpoint: s.URL})
+	a.Track("y", nil)
+	a.Flush()
+
+	m := s.Messages()
+
+	if len(m) != 4 || m[0]["type"] != "identify" || m[2]["type"] != "identify" {
+		t.Fatalf("expected the traits to be sent on each flush, got %v", m)
+	}
+
+	if m[0]["anonymousId"] != a.anonymousID {
+		t.Fatalf("expected the anonymous id, got %v", m[0]["anonymousId"])
+	}
+}
+
+func TestIdentify_latest(t *testing.T) {
+	testHome(t)
+	var sent []*Event
+
+	a := New(&Config{
+		WriteKey: "k",
+		Dir:      ".id",
+		Transport: TransportFunc(func(_ string, events []*Event) error {
+			sent = events
+			return nil
+		}),
+	})
+
+	a.Identify(map[string]interface{}{"plan": "free"})
+	a.Track("x", nil)
+	a.Identify(map[string]interface{}{"plan": "pro"})
+	a.Identify(map[string]interface{}{"plan": "team"})
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var identifies []*Event
+
+	for _, e := range sent {
+		if e.Type == TypeIdentify {
+			identifies = append(identifies, e)
+		}
+	}
+
+	if len(identifies) != 1 || identifies[0].Traits["plan"] != "team" {
+		t.Fatalf("expected only the latest traits, got %v", identifies)
+	}
+}
+
+func TestIdentify_malformed(t *testing.T) {
+	home := testHome(t)
+
+	for _, s := range []string{"", "{bad", "null"} {
+		tr := &recordTransport{}
+		a := New(&Config{WriteKey: "k", Dir: ".id", Transport: tr})
+		ioutil.WriteFile(filepath.Join(home, ".id", "traits"), []byte(s), 0600)
+		a.Track("x", nil)
+
+		if err := a.Flush(); err != nil {
+			t.Fatalf("%q: %s", s, err)
+		}
+
+		if len(tr.events) != 1 || tr.events[0].Type == TypeIdentify {
+			t.Fatalf("%q: expected the traits ignored, got %v", s, tr.events)
+		}
+	}
+}
+
+func TestIdentify_userID(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".id", Endpoint: s.URL})
+	a.SetUserID("acct-1")
+
+	if err := a.Identify(map[string]interface{}{"plan": "pro"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := s.Messages()
+
+	if len(m) != 1 || m[0]["type"] != "identify" || m[0]["userId"] != "acct-1" {
+		t.Fatalf("expected the identify delivered with the user id, got %v", m)
+	}
+}
@@ -0,0 +1,47 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestID_legacy(t *testing.T) {
+	home := testHome(t)
+	os.MkdirAll(filepath.Join(home, ".idfile"), 0755)
+	ioutil.WriteFile(filepath.Join(home, ".idfile", "id"), []byte("legacy-id"), 0600)
+
+	a := New(&Config{WriteKey: "k", Dir: ".idfile"})
+
+	if a.anonymousID != "legacy-id" {
+		t.Fatalf("expected the legacy id, got %q", a.anonymousID)
+	}
+}
+
+func TestID_json(t *testing.T) {
+	home := testHome(t)
+	os.MkdirAll(filepath.Join(home, ".idfile"), 0755)
+	ioutil.WriteFile(filepath.Join(home, ".idfile", "id"), []byte(`{"id":"json-id","created_at":"2020-01-01T00:00:00Z","version":1}`), 0600)
+
+	a := New(&Config{WriteKey: "k", Dir: ".idfile"})
+
+	if a.anonymousID != "json-id" {
+		t.Fatalf("expected the json id, got %q", a.anonymousID)
+	}
+}
+
+func TestID_save(t *testing.T) {
+	home := testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".idfile"})
+	b, _ := ioutil.ReadFile(filepath.Join(home, ".idfile", "id"))
+
+	if id := parseID(b); id == "" || id == string(b) || id != a.anonymousID {
+		t.Fatalf("expected the id saved as JSON, got %s", b)
+	}
+
+	if b := New(&Config{WriteKey: "k", Dir: ".idfile"}); b.anonymousID != a.anonymousID {
+		t.Fatalf("expected the id %q to persist, got %q", a.anonymousID, b.anonymousID)
+	}
+}
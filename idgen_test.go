@@ -0,0 +1,34 @@
+package analytics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIDGenerator(t *testing.T) {
+	home := testHome(t)
+	var out bytes.Buffer
+
+	a := New(&Config{
+		WriteKey:    "k",
+		Dir:         ".idg",
+		Output:      &out,
+		IDGenerator: func() (string, error) { return "fixed-id", nil },
+	})
+
+	b, _ := ioutil.ReadFile(filepath.Join(home, ".idg", "id"))
+
+	if !strings.Contains(string(b), "fixed-id") {
+		t.Fatalf("expected the generated id stored, got %q", b)
+	}
+
+	a.Track("x", nil)
+	a.Flush()
+
+	if !strings.Contains(out.String(), `"fixed-id"`) {
+		t.Fatalf("expected the generated id sent, got %s", out.String())
+	}
+}
@@ -0,0 +1,25 @@
+package analytics
+
+import "testing"
+
+func TestInstallSource(t *testing.T) {
+	testHome(t)
+	t.Setenv("MYAPP_INSTALL_SOURCE", "homebrew")
+
+	a := New(&Config{WriteKey: "k", Dir: ".install", InstallSource: InstallSourceFromEnv("MYAPP_INSTALL_SOURCE")})
+	a.Track("x", nil)
+
+	events, _ := a.Events()
+
+	if events[0].Properties["install_source"] != "homebrew" {
+		t.Fatalf("expected the install source, got %v", events[0].Properties)
+	}
+}
+
+func TestInstallSourceFromEnv(t *testing.T) {
+	t.Setenv("MYAPP_INSTALL_SOURCE", "")
+
+	if s := InstallSourceFromEnv("MYAPP_INSTALL_SOURCE"); s != "unknown" {
+		t.Fatalf("expected unknown, got %q", s)
+	}
+}
@@ -0,0 +1,50 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTrackWithIntegrations(t *testing.T) {
+	testHome(t)
+	var mu sync.Mutex
+	var body string
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		body += string(b)
+		mu.Unlock()
+	}))
+	defer s.Close()
+
+	for _, direct := range []bool{false, true} {
+		mu.Lock()
+		body = ""
+		mu.Unlock()
+
+		a := New(&Config{WriteKey: "k", Dir: t.TempDir(), Endpoint: s.URL, DirectHTTP: direct, Integrations: map[string]interface{}{"All": true}})
+		a.TrackWithIntegrations("x", nil, map[string]interface{}{"All": false, "Warehouse": true})
+		a.Track("y", nil)
+
+		if err := a.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		mu.Lock()
+		got := body
+		mu.Unlock()
+
+		if !strings.Contains(got, `"integrations":{"All":false,"Warehouse":true}`) {
+			t.Fatalf("expected the per-event integrations with direct=%v, got %s", direct, got)
+		}
+
+		if !strings.Contains(got, `"integrations":{"All":true}`) {
+			t.Fatalf("expected the default integrations with direct=%v, got %s", direct, got)
+		}
+	}
+}
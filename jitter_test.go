@@ -0,0 +1,43 @@
+package analytics
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestFlushJitter(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".jitter", FlushJitter: time.Minute, RandSource: rand.NewSource(1)})
+	b := New(&Config{WriteKey: "k", Dir: ".jitter", FlushJitter: time.Minute, RandSource: rand.NewSource(1)})
+	shifted := false
+
+	for i := 0; i < 100; i++ {
+		d := a.jittered(time.Hour)
+
+		if d < 59*time.Minute || d > 61*time.Minute {
+			t.Fatalf("expected within a minute of an hour, got %s", d)
+		}
+
+		if d != b.jittered(time.Hour) {
+			t.Fatal("expected the same jitter from the same source")
+		}
+
+		if d != time.Hour {
+			shifted = true
+		}
+	}
+
+	if !shifted {
+		t.Fatal("expected the interval to be shifted")
+	}
+}
+
+func TestFlushJitter_zero(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".jitter"})
+
+	if d := a.jittered(time.Hour); d != time.Hour {
+		t.Fatalf("expected no jitter, got %s", d)
+	}
+}
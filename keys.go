@@ -0,0 +1,110 @@
+package analytics
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// KeyCase is a property key naming convention.
+type KeyCase int
+
+// Key cases.
+const (
+	AsIs      KeyCase = iota // AsIs leaves keys unchanged
+	SnakeCase                // SnakeCase such as "file_name"
+	CamelCase                // CamelCase such as "fileName"
+)
+
+// normalizeKeys returns `props` with top-level keys converted to case `c`.
+// When keys collide after conversion, the key already in case `c` wins,
+// otherwise the first key in sorted order.
+func (a *Analytics) normalizeKeys(props map[string]interface{}, c KeyCase) map[string]interface{} {
+	if c == AsIs || props == nil {
+		return props
+	}
+
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	v := make(map[string]interface{}, len(props))
+	from := make(map[string]string, len(props))
+
+	for _, k := range keys {
+		n := convertKey(k, c)
+
+		if prev, ok := from[n]; ok {
+			a.Log.WithField("key", k).WithField("collides", prev).Debug("property key collision")
+
+			if prev == n || k != n {
+				continue
+			}
+		}
+
+		v[n] = props[k]
+		from[n] = k
+	}
+
+	return v
+}
+
+// convertKey converts `s` to case `c`.
+func convertKey(s string, c KeyCase) string {
+	words := splitWords(s)
+
+	switch c {
+	case SnakeCase:
+		for i, w := range words {
+			words[i] = strings.ToLower(w)
+		}
+		return strings.Join(words, "_")
+	case CamelCase:
+		for i, w := range words {
+			w = strings.ToLower(w)
+			if i > 0 {
+				w = strings.ToUpper(w[:1]) + w[1:]
+			}
+			words[i] = w
+		}
+		return strings.Join(words, "")
+	default:
+		return s
+	}
+}
+
+// splitWords splits `s` on separators and case changes, for
+// example "HTTPStatus code" yields "HTTP", "Status", "code".
+func splitWords(s string) (words []string) {
+	var word []rune
+	runes := []rune(s)
+
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-' || r == '.' || unicode.IsSpace(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0:
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+
+		word = append(word, r)
+	}
+
+	flush()
+	return words
+}
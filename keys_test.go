@@ -0,0 +1,35 @@
+package analytics
+
+import "testing"
+
+func TestConvertKey(t *testing.T) {
+	cases := map[string][2]string{
+		"fileName":   {"file_name", "fileName"},
+		"File Name":  {"file_name", "fileName"},
+		"HTTPStatus": {"http_status", "httpStatus"},
+		"file-name":  {"file_name", "fileName"},
+		"already_ok": {"already_ok", "alreadyOk"},
+		"v2Thing":    {"v2_thing", "v2Thing"},
+	}
+
+	for in, want := range cases {
+		if s := convertKey(in, SnakeCase); s != want[0] {
+			t.Errorf("expected %q as snake case %q, got %q", in, want[0], s)
+		}
+
+		if s := convertKey(in, CamelCase); s != want[1] {
+			t.Errorf("expected %q as camel case %q, got %q", in, want[1], s)
+		}
+	}
+}
+
+func TestNormalizeKeys_collision(t *testing.T) {
+	a := &Analytics{Config: &Config{}}
+	a.defaults()
+
+	v := a.normalizeKeys(map[string]interface{}{"fileName": 1, "file_name": 2, "FileName": 3}, SnakeCase)
+
+	if len(v) != 1 || v["file_name"] != 2 {
+		t.Fatalf("expected the already normalized key to win, got %v", v)
+	}
+}
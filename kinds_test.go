@@ -0,0 +1,35 @@
+package analytics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	testHome(t)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".kinds", Endpoint: s.URL, FlushBackoff: 1})
+	a.Track("x", nil)
+	err := a.Flush()
+
+	if !errors.Is(err, ErrFlushDelivery) || errors.Is(err, ErrStorage) {
+		t.Fatalf("expected a delivery error, got %v", err)
+	}
+
+	var rerr *RejectedError
+
+	if errors.As(err, &rerr) {
+		t.Fatalf("expected a transient error, got %v", err)
+	}
+
+	if !errors.Is(&RejectedError{}, ErrFlushDelivery) {
+		t.Fatal("expected RejectedError to be a delivery error")
+	}
+}
@@ -0,0 +1,32 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestLastFlushDuration_missing(t *testing.T) {
+	home := testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".lf", Endpoint: s.URL})
+	a.Track("x", nil)
+	os.Remove(filepath.Join(home, ".lf", "last_flush"))
+
+	if _, err := a.LastFlushDuration(); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error, got %v", err)
+	}
+
+	if err := a.ConditionalFlush(100, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.Messages()); n != 1 {
+		t.Fatalf("expected a flush without a last flush, got %d messages", n)
+	}
+}
@@ -0,0 +1,41 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLastFlushSuccess(t *testing.T) {
+	testHome(t)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &fakeClock{t: start}
+
+	a := New(&Config{WriteKey: "k", Dir: ".success", Clock: c, Transport: &countTransport{}})
+	a.Track("x", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.t = start.Add(time.Hour)
+	b := New(&Config{WriteKey: "k", Dir: ".success", Clock: c, Transport: failTransport{}})
+	b.Track("x", nil)
+
+	if err := b.Flush(); err == nil {
+		t.Fatal("expected the flush to fail")
+	}
+
+	b = New(&Config{WriteKey: "k", Dir: ".success", Clock: c})
+
+	if last, _ := b.LastFlush(); !last.Equal(c.t) {
+		t.Fatalf("expected the attempt recorded at %s, got %s", c.t, last)
+	}
+
+	if last, _ := b.LastFlushSuccess(); !last.Equal(start) {
+		t.Fatalf("expected the last success at %s, got %s", start, last)
+	}
+
+	if d, _ := b.LastFlushSuccessDuration(); d != time.Hour {
+		t.Fatalf("expected 1h since success, got %s", d)
+	}
+}
@@ -0,0 +1,53 @@
+package analytics
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	testHome(t)
+	ch := make(chan LifecycleEvent, 10)
+
+	a := New(&Config{WriteKey: "k", Dir: ".lifecycle", Transport: &countTransport{}, Lifecycle: ch})
+	a.Track("x", nil)
+	a.Flush()
+	a.Close()
+
+	b := New(&Config{WriteKey: "k", Dir: ".lifecycle", Transport: &countTransport{err: errors.New("boom")}, Lifecycle: ch})
+	b.Track("y", nil)
+	b.Flush()
+	b.Disable()
+	New(&Config{WriteKey: "k", Dir: ".lifecycle", Lifecycle: ch})
+	close(ch)
+
+	var states []string
+
+	for e := range ch {
+		states = append(states, e.State)
+	}
+
+	expected := []string{StateInitialized, StateFlushed, StateClosed, StateInitialized, StateError, StateDisabled}
+
+	if len(states) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, states)
+	}
+
+	for i, s := range expected {
+		if states[i] != s {
+			t.Fatalf("expected %v, got %v", expected, states)
+		}
+	}
+}
+
+func TestLifecycle_full(t *testing.T) {
+	testHome(t)
+	ch := make(chan LifecycleEvent)
+
+	within(t, time.Second, func() {
+		a := New(&Config{WriteKey: "k", Dir: ".lifecycle", Transport: &countTransport{}, Lifecycle: ch})
+		a.Track("x", nil)
+		a.Flush()
+	})
+}
@@ -0,0 +1,44 @@
+package analytics
+
+import (
+	"math"
+	"time"
+)
+
+// limiter is a token bucket allowing `rate` events per second,
+// with a burst of the same size.
+type limiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newLimiter returns a full limiter of `rate` events per second.
+func newLimiter(rate float64) *limiter {
+	burst := math.Max(rate, 1)
+
+	return &limiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+	}
+}
+
+// allow takes a token at `now`. When none are available false is
+// returned, along with the duration until one is.
+func (l *limiter) allow(now time.Time) (bool, time.Duration) {
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+
+	wait := (1 - l.tokens) / l.rate * float64(time.Second)
+	return false, time.Duration(wait)
+}
@@ -0,0 +1,104 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/gofrs/flock"
+	"github.com/pkg/errors"
+)
+
+// init ~/<dir>/lock, guarding the buffer files against other processes,
+// and ~/<dir>/flush.lock, held by the process flushing.
+func (a *Analytics) initLocks() {
+	if a.bufferLock != nil {
+		return
+	}
+
+	a.bufferLock = flock.New(filepath.Join(a.root, "lock"))
+	a.flushLock = flock.New(filepath.Join(a.root, "flush.lock"))
+}
+
+// shared returns true when the buffer is on disk, shared with
+// other processes, which is the events files or the state.
+func (a *Analytics) shared() bool {
+	if a.store == nil {
+		return true
+	}
+
+	_, ok := a.store.(*stateStore)
+	return ok
+}
+
+// lockBuffer waits for ~/<dir>/lock, returning a func releasing it.
+func (a *Analytics) lockBuffer() (func(), error) {
+	if a.bufferLock == nil || !a.shared() {
+		return func() {}, nil
+	}
+
+	l := a.bufferLock
+
+	if err := l.Lock(); err != nil {
+		return nil, errors.Wrap(err, "locking buffer")
+	}
+
+	return func() {
+		if err := l.Unlock(); err != nil {
+			a.Log.WithError(err).Debug("error unlocking buffer")
+		}
+	}, nil
+}
+
+// tryLockFlush acquires ~/<dir>/flush.lock without waiting, returning
+// false when another process is flushing, or this one is delivering.
+func (a *Analytics) tryLockFlush() (bool, func(), error) {
+	if a.flushing {
+		return false, nil, nil
+	}
+
+	if a.flushLock == nil || !a.shared() {
+		return true, func() {}, nil
+	}
+
+	l := a.flushLock
+
+	ok, err := l.TryLock()
+	if err != nil {
+		return false, nil, errors.Wrap(err, "locking flush")
+	}
+
+	if !ok {
+		return false, nil, nil
+	}
+
+	return true, func() {
+		if err := l.Unlock(); err != nil {
+			a.Log.WithError(err).Debug("error unlocking flush")
+		}
+	}, nil
+}
+
+// reopenMoved reopens the events file when it has been moved or replaced
+// by another process, such as when snapshotted by a flush, so that the
+// event isn't appended to a file which is no longer the buffer.
+func (a *Analytics) reopenMoved() error {
+	if a.eventsFile == nil {
+		return nil
+	}
+
+	info, err := a.eventsFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	cur, err := os.Stat(filepath.Join(a.root, "events"))
+	if err == nil && os.SameFile(info, cur) {
+		return nil
+	}
+
+	a.Log.Debug("events file moved, reopening")
+	a.closeEvents()
+	a.countSize = -1
+
+	return a.openEvents()
+}
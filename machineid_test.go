@@ -0,0 +1,33 @@
+package analytics
+
+import "testing"
+
+func TestMachineID(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".machine"})
+
+	id, err := a.MachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again, _ := a.MachineID(); again != id || len(id) != 64 {
+		t.Fatalf("expected a stable hashed id, got %q and %q", id, again)
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".machine", MachineIDSalt: "other"})
+
+	if other, _ := b.MachineID(); other == id {
+		t.Fatal("expected the salt to change the id")
+	}
+}
+
+func TestMachineID_fallback(t *testing.T) {
+	t.Setenv("HOME", "/proc")
+	a := New(&Config{WriteKey: "k", Dir: ".machine", FallbackInMemory: true})
+	id, _ := a.MachineID()
+
+	if a.UserID() != id {
+		t.Fatalf("expected the machine id when the id can't be persisted, got %q", a.UserID())
+	}
+}
@@ -0,0 +1,38 @@
+package analytics
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMaxEvents(t *testing.T) {
+	testHome(t)
+	var dropped int
+
+	a := New(&Config{
+		WriteKey:  "k",
+		Dir:       ".max",
+		MaxEvents: 5,
+		OnDrop: func(e *Event, err error) {
+			if err == ErrBufferFull {
+				dropped++
+			}
+		},
+	})
+
+	for i := 0; i < 10; i++ {
+		if err := a.Track(fmt.Sprint(i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, _ := a.Events()
+
+	if len(events) != 5 || events[0].Event != "5" || events[4].Event != "9" {
+		t.Fatalf("expected the newest 5 events, got %v", events)
+	}
+
+	if dropped != 5 {
+		t.Fatalf("expected 5 events dropped, got %d", dropped)
+	}
+}
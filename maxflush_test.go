@@ -0,0 +1,41 @@
+package analytics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaxFlushDuration(t *testing.T) {
+	testHome(t)
+	done := make(chan struct{})
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-done:
+		}
+	}))
+	defer s.Close()
+	defer close(done)
+
+	for _, mode := range []ClearMode{ClearRemove, ClearTruncate} {
+		dir := t.TempDir()
+		a := New(&Config{WriteKey: "k", Dir: dir, Endpoint: s.URL, MaxFlushDuration: 100 * time.Millisecond, FlushClearMode: mode})
+		a.Track("x", nil)
+		start := time.Now()
+
+		if err := a.Flush(); err != ErrFlushTimeout {
+			t.Fatalf("expected ErrFlushTimeout, got %v", err)
+		}
+
+		if d := time.Since(start); d > time.Second {
+			t.Fatalf("expected the client close to be bounded, took %s", d)
+		}
+
+		if n, _ := New(&Config{WriteKey: "k", Dir: dir}).Size(); n != 1 {
+			t.Fatalf("expected the event kept with mode %v, got %d", mode, n)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package analytics
+
+import (
+	"fmt"
+	"io"
+)
+
+// Metrics are in-process counters of tracker activity.
+type Metrics struct {
+	Tracked       int // Tracked is the number of events buffered
+	Dropped       int // Dropped is the number of events dropped
+	Flushed       int // Flushed is the number of events delivered
+	FlushFailures int // FlushFailures is the number of failed flushes
+}
+
+// Metrics returns the counters for this process, which are only zeroed by Reset().
+func (a *Analytics) Metrics() Metrics {
+	a.mu.Lock()
+	defer a.unlock()
+	return a.metrics
+}
+
+// Stats is an alias of Metrics, the counters of tracked, flushed and dropped events.
+type Stats = Metrics
+
+// Stats returns the same counters as Metrics().
+func (a *Analytics) Stats() Stats {
+	return a.Metrics()
+}
+
+// WritePrometheus writes the metrics to `w` in the Prometheus text
+// exposition format, with metric names prefixed by `prefix`.
+func (m Metrics) WritePrometheus(w io.Writer, prefix string) error {
+	counters := []struct {
+		name  string
+		help  string
+		value int
+	}{
+		{"events_tracked_total", "Events buffered.", m.Tracked},
+		{"events_dropped_total", "Events dropped.", m.Dropped},
+		{"events_flushed_total", "Events delivered.", m.Flushed},
+		{"flush_failures_total", "Failed flushes.", m.FlushFailures},
+	}
+
+	for _, c := range counters {
+		name := prefix + c.name
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, c.help, name, name, c.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
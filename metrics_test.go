@@ -0,0 +1,65 @@
+package analytics
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{err: errors.New("boom")}
+	a := New(&Config{WriteKey: "k", Dir: ".metrics", Transport: tr, DisabledEvents: []string{"noisy"}})
+
+	a.Track("a", nil)
+	a.Track("b", nil)
+	a.Track("noisy", nil)
+	a.Flush()
+
+	m := a.Metrics()
+
+	if m.Tracked != 2 || m.Dropped != 1 || m.Flushed != 0 || m.FlushFailures != 1 {
+		t.Fatalf("expected 2 tracked, 1 dropped and 1 failure, got %+v", m)
+	}
+}
+
+func TestMetrics_reset(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".metrics", Transport: &countTransport{}})
+
+	a.Track("a", nil)
+
+	if m := a.Metrics(); m.Tracked != 1 {
+		t.Fatalf("expected 1 tracked, got %+v", m)
+	}
+
+	if err := a.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := a.Metrics(); m != (Metrics{}) {
+		t.Fatalf("expected the counters zeroed, got %+v", m)
+	}
+}
+
+func TestStats(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".metrics", Transport: &countTransport{}, DisabledEvents: []string{"noisy"}})
+
+	a.Track("a", nil)
+	a.Track("b", nil)
+	a.Track("noisy", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := a.Stats()
+
+	if s.Tracked != 2 || s.Dropped != 1 || s.Flushed != 2 || s.FlushFailures != 0 {
+		t.Fatalf("expected 2 tracked, 1 dropped and 2 flushed, got %+v", s)
+	}
+
+	if s != a.Metrics() {
+		t.Fatalf("expected the stats to match the metrics, got %+v", s)
+	}
+}
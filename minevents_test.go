@@ -0,0 +1,58 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMinEventsToFlush(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+	a := New(&Config{WriteKey: "k", Dir: ".min", Transport: tr, MinEventsToFlush: 3})
+
+	a.Track("a", nil)
+	a.Track("b", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.ConditionalFlush(1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.sends != 0 {
+		t.Fatalf("expected no upload below the threshold, got %d", tr.sends)
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".min", Transport: tr, MinEventsToFlush: 3})
+	b.Track("c", nil)
+
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.n != 3 {
+		t.Fatalf("expected 3 events uploaded at the threshold, got %d", tr.n)
+	}
+}
+
+func TestForceFlush(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+	a := New(&Config{WriteKey: "k", Dir: ".min", Transport: tr, MinEventsToFlush: 3})
+	a.Touch()
+	a.Track("a", nil)
+
+	if err := a.ConditionalFlush(100, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.ForceFlush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.n != 1 {
+		t.Fatalf("expected ForceFlush to ignore the threshold, got %d", tr.n)
+	}
+}
@@ -0,0 +1,36 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSize_missing(t *testing.T) {
+	home := testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".missing"})
+	a.Disable()
+	a.Close()
+	os.Remove(filepath.Join(home, ".missing", "events"))
+
+	b := New(&Config{WriteKey: "k", Dir: ".missing"})
+
+	if n, err := b.Size(); n != 0 || err != nil {
+		t.Fatalf("expected 0 and no error, got %d and %v", n, err)
+	}
+
+	if e, err := b.Events(); len(e) != 0 || err != nil {
+		t.Fatalf("expected no events and no error, got %v and %v", e, err)
+	}
+
+	os.Mkdir(filepath.Join(home, ".missing", "events"), 0700)
+
+	if _, err := b.Size(); err == nil {
+		t.Fatal("expected a read error from Size")
+	}
+
+	if _, err := b.Events(); err == nil {
+		t.Fatal("expected a read error from Events")
+	}
+}
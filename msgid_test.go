@@ -0,0 +1,56 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestMessageID(t *testing.T) {
+	testHome(t)
+	var mu sync.Mutex
+	var ids []string
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v struct {
+			Batch []struct {
+				MessageID string `json:"messageId"`
+			} `json:"batch"`
+		}
+
+		json.NewDecoder(r.Body).Decode(&v)
+		mu.Lock()
+		for _, m := range v.Batch {
+			ids = append(ids, m.MessageID)
+		}
+		mu.Unlock()
+	}))
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".msgid", Endpoint: s.URL})
+	a.Track("a", nil)
+	a.Track("b", nil)
+
+	events, err := a.Events()
+
+	if err != nil || len(events) != 2 {
+		t.Fatalf("expected 2 events, got %v and %v", events, err)
+	}
+
+	if events[0].MessageID == "" || events[0].MessageID == events[1].MessageID {
+		t.Fatalf("expected unique message ids, got %q and %q", events[0].MessageID, events[1].MessageID)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(ids) != 2 || ids[0] != events[0].MessageID || ids[1] != events[1].MessageID {
+		t.Fatalf("expected the persisted message ids sent, got %v", ids)
+	}
+}
@@ -0,0 +1,43 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNamespace(t *testing.T) {
+	home := testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".ns", Namespace: "a", Transport: &countTransport{}})
+	b := New(&Config{WriteKey: "k", Dir: ".ns", Namespace: "b", Transport: &countTransport{}})
+	a.Track("x", nil)
+	b.Track("y", nil)
+	b.Track("y", nil)
+
+	if a.UserID() == b.UserID() {
+		t.Fatalf("expected separate ids, got %q for both", a.UserID())
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if events, _ := b.Events(); len(events) != 2 {
+		t.Fatalf("expected the other namespace untouched, got %d events", len(events))
+	}
+
+	if _, err := os.Stat(filepath.Join(home, ".ns", "b", "events")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamespace_invalid(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".ns", Namespace: "../x"})
+
+	if id := a.UserID(); id != "" {
+		t.Fatalf("expected an invalid namespace to disable tracking, got %q", id)
+	}
+}
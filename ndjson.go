@@ -0,0 +1,81 @@
+package analytics
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Line is the record written to Config.Output for each flushed event,
+// encoded as a single line of JSON (NDJSON). Track events look like:
+//
+//	{"type":"track","event":"Build","user_id":"","anonymous_id":"<id>","properties":{"n":1},"timestamp":"2017-01-02T15:04:05Z"}
+//
+// The "user_id" is empty unless set by SetUserID, and is always empty for events
+// tracked with TrackAnonymous. Alias events carry the new id as "user_id" and the previous id as "previous_id".
+// Identify events carry the traits set by Identify as "traits", and group events
+// the group id and traits set by Group as "group_id" and "traits". The Context is
+// included as "context" when set.
+type Line struct {
+	Type        string                 `json:"type"`
+	Event       string                 `json:"event,omitempty"`
+	UserID      string                 `json:"user_id"`
+	AnonymousID string                 `json:"anonymous_id,omitempty"`
+	PreviousID  string                 `json:"previous_id,omitempty"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+	Traits      map[string]interface{} `json:"traits,omitempty"`
+	GroupID     string                 `json:"group_id,omitempty"`
+	Context     map[string]interface{} `json:"context,omitempty"`
+	Timestamp   time.Time              `json:"timestamp"`
+}
+
+// writeLines writes `events` to Output as NDJSON.
+func (a *Analytics) writeLines(events []*Event) error {
+	enc := json.NewEncoder(a.Output)
+
+	for _, e := range events {
+		if err := enc.Encode(a.line(e)); err != nil {
+			return errors.Wrap(err, "writing")
+		}
+	}
+
+	return nil
+}
+
+// line returns the Line for event `e`.
+func (a *Analytics) line(e *Event) *Line {
+	l := &Line{
+		Type:        TypeTrack,
+		Event:       e.Event,
+		UserID:      a.userID,
+		AnonymousID: a.anonymousID,
+		Properties:  e.Properties,
+		Context:     a.context(),
+		Timestamp:   e.Timestamp,
+	}
+
+	if e.Anonymous {
+		l.UserID = ""
+	}
+
+	if e.Type == TypeAlias {
+		l.Type = TypeAlias
+		l.UserID = e.UserID
+		l.AnonymousID = ""
+		l.PreviousID = e.PreviousID
+	}
+
+	if e.Type == TypeIdentify {
+		l.Type = TypeIdentify
+		l.Traits = e.Traits
+	}
+
+	if e.Type == TypeGroup {
+		l.Type = TypeGroup
+		l.GroupID = e.GroupID
+		l.Traits = e.Traits
+	}
+
+	return l
+}
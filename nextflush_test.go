@@ -0,0 +1,34 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNextFlushIn(t *testing.T) {
+	home := testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".nf"})
+	path := filepath.Join(home, ".nf", "last_flush")
+
+	os.Remove(path)
+
+	if d, err := a.NextFlushIn(time.Hour); d != 0 || err != nil {
+		t.Fatalf("expected a flush due without a last flush, got %s and %v", d, err)
+	}
+
+	a.Touch()
+
+	if d, _ := a.NextFlushIn(time.Hour); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("expected about an hour, got %s", d)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	ioutil.WriteFile(path, []byte(old.Format(time.RFC3339)), 0600)
+
+	if d, _ := a.NextFlushIn(time.Hour); d != 0 {
+		t.Fatalf("expected a flush overdue, got %s", d)
+	}
+}
@@ -0,0 +1,30 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeToNextFlush(t *testing.T) {
+	testHome(t)
+	c := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	a := New(&Config{WriteKey: "k", Dir: ".ttnf", Clock: c})
+	a.Touch()
+	a.Track("x", nil)
+
+	c.t = c.t.Add(time.Hour)
+
+	if d, err := a.TimeToNextFlush(10, 3*time.Hour); err != nil || d != 2*time.Hour {
+		t.Fatalf("expected 2h, got %s and %v", d, err)
+	}
+
+	if d, _ := a.TimeToNextFlush(1, 3*time.Hour); d != 0 {
+		t.Fatalf("expected 0 when the size would flush, got %s", d)
+	}
+
+	c.t = c.t.Add(3 * time.Hour)
+
+	if d, _ := a.TimeToNextFlush(10, 3*time.Hour); d != 0 {
+		t.Fatalf("expected 0 when overdue, got %s", d)
+	}
+}
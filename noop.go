@@ -0,0 +1,16 @@
+package analytics
+
+// NewDisabled returns a tracker with analytics compiled out, such as
+// in a build for distributions selected by a build tag. Unlike the
+// disable file its methods are no-ops which never touch the filesystem
+// or network, and Enabled() returns false.
+func NewDisabled() *Analytics {
+	config := &Config{}
+	config.defaults()
+
+	return &Analytics{
+		Config:    config,
+		countSize: -1,
+		noop:      true,
+	}
+}
@@ -0,0 +1,108 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNewDisabled(t *testing.T) {
+	home := testHome(t)
+	cwd := t.TempDir()
+
+	wd, _ := os.Getwd()
+	os.Chdir(cwd)
+	defer os.Chdir(wd)
+
+	a := NewDisabled()
+	a.init()
+
+	if ok, err := a.Enabled(); ok || err != nil {
+		t.Fatalf("expected disabled, got %v and %v", ok, err)
+	}
+
+	for _, err := range []error{
+		a.Track("x", map[string]interface{}{"a": 1}),
+		a.TrackPriority("x", nil, PriorityHigh),
+		a.Identify(map[string]interface{}{"a": 1}),
+		a.Group("g", nil),
+		a.Alias("u"),
+		a.SetUserID("u"),
+		a.Flush(),
+		a.ForceFlush(),
+		a.ConditionalFlush(0, 0),
+		a.Drain(),
+		a.Touch(),
+		a.DisableUntil(time.Now().Add(time.Hour)),
+		a.GrantConsent(),
+		a.ConsentCategory("c"),
+		a.Reset(),
+		a.Compact(),
+		a.Recover(),
+		a.Export(&bytes.Buffer{}),
+		a.Ping(context.Background()),
+	} {
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	a.Disable()
+	a.Enable()
+	a.RotateID()
+	a.Events()
+	a.Size()
+	a.LastFlush()
+	a.TimeToNextFlush(1, time.Second)
+	a.StartFlusher(1, time.Millisecond)()
+	a.FlushOnSignal()()
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{home, cwd} {
+		if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+			t.Fatalf("expected nothing written to %s, got %d files", dir, len(files))
+		}
+	}
+}
+
+func TestNewDisabled_methods(t *testing.T) {
+	home := testHome(t)
+	cwd := t.TempDir()
+
+	wd, _ := os.Getwd()
+	os.Chdir(cwd)
+	defer os.Chdir(wd)
+
+	a := NewDisabled()
+	all := func(*Event) bool { return true }
+
+	if n, err := a.FlushFunc(all); n != 0 || err != nil {
+		t.Fatalf("expected nothing flushed, got %d and %v", n, err)
+	}
+
+	for _, err := range []error{
+		a.Alias("u"),
+		a.Group("g", map[string]interface{}{"a": 1}),
+		a.Identify(map[string]interface{}{"a": 1}),
+		a.Import(bytes.NewBufferString(`{"event":"x"}` + "\n")),
+		a.Compact(),
+		a.TrackBatch([]*Event{{Event: "x"}}),
+		a.Recover(),
+	} {
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	for _, dir := range []string{home, cwd} {
+		if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+			t.Fatalf("expected nothing written to %s, got %d files", dir, len(files))
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package analytics
+
+import "testing"
+
+func TestOfflineCheck(t *testing.T) {
+	testHome(t)
+	offline := true
+	tr := &countTransport{}
+
+	a := New(&Config{WriteKey: "k", Dir: ".off", Transport: tr, OfflineCheck: func() bool { return offline }})
+	a.Track("x", nil)
+
+	if err := a.Flush(); err != ErrOffline {
+		t.Fatalf("expected ErrOffline, got %v", err)
+	}
+
+	if err := a.ConditionalFlush(0, 0); err != ErrOffline {
+		t.Fatalf("expected ErrOffline, got %v", err)
+	}
+
+	if n, _ := a.Size(); n != 1 || tr.sends != 0 {
+		t.Fatalf("expected the event kept without sending, got %d and %d sends", n, tr.sends)
+	}
+
+	offline = false
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.n != 1 {
+		t.Fatalf("expected the event delivered, got %d", tr.n)
+	}
+}
@@ -0,0 +1,53 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestOnRequest(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	for _, direct := range []bool{false, true} {
+		var bodies []string
+		var statuses []int
+
+		a := New(&Config{
+			WriteKey:   "k",
+			Dir:        t.TempDir(),
+			Endpoint:   s.URL,
+			DirectHTTP: direct,
+			OnRequest: func(req *http.Request, res *http.Response, err error) {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+					return
+				}
+
+				body, _ := req.GetBody()
+				b, _ := ioutil.ReadAll(body)
+				bodies = append(bodies, string(b))
+				statuses = append(statuses, res.StatusCode)
+			},
+		})
+
+		a.Track("Build", nil)
+
+		if err := a.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(statuses) != 1 || statuses[0] != http.StatusOK {
+			t.Fatalf("expected 1 OK response with direct=%v, got %v", direct, statuses)
+		}
+
+		if !strings.Contains(bodies[0], `"event":"Build"`) {
+			t.Fatalf("expected the request payload, got %s", bodies[0])
+		}
+	}
+}
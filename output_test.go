@@ -0,0 +1,39 @@
+package analytics
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutput(t *testing.T) {
+	testHome(t)
+	var out bytes.Buffer
+	ts := time.Date(2017, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	a := New(&Config{WriteKey: "k", Dir: ".output", Output: &out})
+	a.TrackAt("Build", map[string]interface{}{"n": 1}, ts)
+	a.TrackAt("Deploy", nil, ts)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", out.String())
+	}
+
+	expected := fmt.Sprintf(`{"type":"track","event":"Build","user_id":"","anonymous_id":%q,"properties":{"n":1},"timestamp":"2017-01-02T15:04:05Z"}`, a.anonymousID)
+
+	if lines[0] != expected {
+		t.Fatalf("expected %s, got %s", expected, lines[0])
+	}
+
+	if !strings.HasPrefix(lines[1], `{"type":"track","event":"Deploy","user_id":""`) {
+		t.Fatalf("expected a track line for Deploy, got %s", lines[1])
+	}
+}
@@ -0,0 +1,30 @@
+package analytics
+
+import (
+	"strings"
+	"testing"
+)
+
+// panicTransport panics on send.
+type panicTransport struct{}
+
+func (panicTransport) Send(string, []*Event) error { panic("boom") }
+func (panicTransport) Close() error                { return nil }
+
+func TestFlush_panic(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".panic", Transport: panicTransport{}})
+	a.Track("x", map[string]interface{}{"n": 1})
+	a.Track("y", nil)
+
+	err := a.Flush()
+
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected the panic returned as an error, got %v", err)
+	}
+
+	if n, _ := New(&Config{WriteKey: "k", Dir: ".panic"}).Size(); n != 2 {
+		t.Fatalf("expected the events kept, got %d", n)
+	}
+}
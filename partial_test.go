@@ -0,0 +1,55 @@
+package analytics
+
+import "testing"
+
+// rejectTransport rejects events with the given name.
+type rejectTransport struct {
+	name string
+}
+
+func (r rejectTransport) Send(id string, events []*Event) error {
+	var rerr RejectedError
+
+	for _, e := range events {
+		if e.Event == r.name {
+			rerr.IDs = append(rerr.IDs, e.MessageID)
+			rerr.Events = append(rerr.Events, e)
+		}
+	}
+
+	if len(rerr.IDs) > 0 {
+		return &rerr
+	}
+
+	return nil
+}
+
+func (rejectTransport) Close() error { return nil }
+
+func TestFlushPartial(t *testing.T) {
+	testHome(t)
+
+	for _, mode := range []ClearMode{ClearRemove, ClearTruncate} {
+		dir := t.TempDir()
+		a := New(&Config{WriteKey: "k", Dir: dir, Transport: rejectTransport{"bad"}, FlushClearMode: mode})
+		a.Track("a", nil)
+		a.Track("bad", nil)
+		a.Track("b", nil)
+
+		n, failed, err := a.FlushPartial()
+
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 delivered with mode %v, got %d and %v", mode, n, err)
+		}
+
+		if len(failed) != 1 || failed[0].Event != "bad" {
+			t.Fatalf("expected the rejected event returned, got %v", failed)
+		}
+
+		events, _ := New(&Config{WriteKey: "k", Dir: dir}).Events()
+
+		if len(events) != 1 || events[0].Event != "bad" {
+			t.Fatalf("expected only the rejected event kept, got %v", events)
+		}
+	}
+}
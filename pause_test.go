@@ -0,0 +1,35 @@
+package analytics
+
+import "testing"
+
+func TestPauseFlush(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+	a := New(&Config{WriteKey: "k", Dir: ".pause", Transport: tr})
+
+	a.PauseFlush()
+	a.Track("a", nil)
+	a.Track("b", nil)
+
+	if err := a.Flush(); err != ErrFlushPaused {
+		t.Fatalf("expected ErrFlushPaused, got %v", err)
+	}
+
+	if err := a.ConditionalFlush(1, 0); err != ErrFlushPaused {
+		t.Fatalf("expected ErrFlushPaused from ConditionalFlush, got %v", err)
+	}
+
+	if n, _ := a.Size(); n != 2 || tr.sends != 0 {
+		t.Fatalf("expected 2 events buffered and no sends, got %d and %d", n, tr.sends)
+	}
+
+	a.ResumeFlush()
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.n != 2 {
+		t.Fatalf("expected 2 events sent after resuming, got %d", tr.n)
+	}
+}
@@ -0,0 +1,43 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPerm(t *testing.T) {
+	home := testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".perm"})
+	a.Track("x", nil)
+	a.Touch()
+
+	root := filepath.Join(home, ".perm")
+
+	if info, _ := os.Stat(root); info.Mode().Perm() != 0700 {
+		t.Fatalf("expected dir mode 0700, got %s", info.Mode())
+	}
+
+	for _, name := range []string{"id", "events", "last_flush"} {
+		info, err := os.Stat(filepath.Join(root, name))
+
+		if err != nil || info.Mode().Perm() != 0600 {
+			t.Fatalf("expected %s mode 0600, got %v and %v", name, info, err)
+		}
+	}
+}
+
+func TestPerm_custom(t *testing.T) {
+	home := testHome(t)
+
+	New(&Config{WriteKey: "k", Dir: ".perm", DirPerm: 0750, FilePerm: 0640})
+
+	if info, _ := os.Stat(filepath.Join(home, ".perm")); info.Mode().Perm() != 0750 {
+		t.Fatalf("expected dir mode 0750, got %s", info.Mode())
+	}
+
+	if info, _ := os.Stat(filepath.Join(home, ".perm", "id")); info.Mode().Perm() != 0640 {
+		t.Fatalf("expected file mode 0640, got %s", info.Mode())
+	}
+}
@@ -0,0 +1,54 @@
+package analytics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestPing(t *testing.T) {
+	testHome(t)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, _, _ := r.BasicAuth(); u != "good" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "bad", Dir: ".ping", Endpoint: s.URL})
+	err := a.Ping(context.Background())
+
+	if err == nil || !strings.Contains(err.Error(), "invalid write key") {
+		t.Fatalf("expected an invalid write key, got %v", err)
+	}
+
+	b := New(&Config{WriteKey: "good", Dir: ".ping", Endpoint: s.URL + "/"})
+
+	if err := b.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPing_status(t *testing.T) {
+	testHome(t)
+
+	for _, code := range []int{http.StatusUnauthorized, http.StatusServiceUnavailable} {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}))
+
+		a := New(&Config{WriteKey: "k", Dir: ".ping", Endpoint: s.URL})
+		err := a.Ping(context.Background())
+		s.Close()
+
+		serr, ok := errors.Cause(err).(*StatusError)
+		if !ok || serr.Code != code {
+			t.Fatalf("expected a *StatusError for %d, got %v", code, err)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package analytics
+
+import "testing"
+
+func TestTrackPreviousEvent(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".prev", TrackPreviousEvent: true})
+
+	a.Track("Login", nil)
+	a.Track("Deploy", nil)
+
+	events, _ := a.Events()
+
+	if _, ok := events[0].Properties["previous_event"]; ok {
+		t.Fatalf("expected no previous event on the first, got %v", events[0].Properties)
+	}
+
+	if events[1].Properties["previous_event"] != "Login" {
+		t.Fatalf("expected the previous event, got %v", events[1].Properties)
+	}
+}
+
+func TestTrackPreviousEvent_persisted(t *testing.T) {
+	testHome(t)
+
+	New(&Config{WriteKey: "k", Dir: ".prev", TrackPreviousEvent: true}).Track("Login", nil)
+
+	b := New(&Config{WriteKey: "k", Dir: ".prev", TrackPreviousEvent: true})
+	b.Track("Deploy", nil)
+
+	events, _ := b.Events()
+
+	if events[1].Properties["previous_event"] != "Login" {
+		t.Fatalf("expected the previous event from the last run, got %v", events[1].Properties)
+	}
+}
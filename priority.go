@@ -0,0 +1,202 @@
+package analytics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Priority of an event.
+type Priority int
+
+// Priorities.
+const (
+	PriorityNormal Priority = iota
+	PriorityHigh
+)
+
+// TrackPriority tracks event `name` with optional `props` at priority `p`.
+// High priority events are buffered separately in ~/<dir>/priority and are
+// flushed by ConditionalFlush once PriorityFlushSize is reached, regardless
+// of the thresholds applied to normal events.
+func (a *Analytics) TrackPriority(name string, props map[string]interface{}, p Priority) error {
+	if p == PriorityNormal {
+		_, err := a.track(name, props, nil)
+		return err
+	}
+
+	_, err := a.track(name, props, func(e *Event) {
+		e.Priority = p
+	})
+
+	return err
+}
+
+// PriorityEvents returns the buffered high priority events.
+func (a *Analytics) PriorityEvents() ([]*Event, error) {
+	if a.noop {
+		return nil, nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+	return a.priorityEvents()
+}
+
+// priorityEvents returns the buffered high priority events.
+func (a *Analytics) priorityEvents() ([]*Event, error) {
+	f, err := os.Open(a.priorityPath())
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "opening")
+	}
+
+	defer f.Close()
+	return a.decodeAll(f)
+}
+
+// priorityPath returns the path of the high priority buffer.
+func (a *Analytics) priorityPath() string {
+	return filepath.Join(a.root, "priority")
+}
+
+// writePriority appends `e` to the high priority buffer.
+func (a *Analytics) writePriority(e *Event) error {
+	f, err := os.OpenFile(a.priorityPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, a.FilePerm)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	if err := a.encoder(f).Encode(e); err != nil {
+		return err
+	}
+
+	if a.Durability >= DurabilityOnEveryWrite {
+		return syncFile(f)
+	}
+
+	return nil
+}
+
+// readPriority returns the high priority events to flush. When `snapshot`
+// is true they are first moved to ~/<dir>/priority.flushing like the events
+// file, otherwise the buffer lock must be held for the duration of the flush.
+func (a *Analytics) readPriority(snapshot bool) ([]*Event, error) {
+	if !snapshot {
+		return a.priorityEvents()
+	}
+
+	return a.snapshotFile(a.priorityPath())
+}
+
+// flushPriority sends the high priority events when PriorityFlushSize
+// is reached, leaving normal events buffered. It's a no-op while
+// another process is flushing.
+func (a *Analytics) flushPriority() (err error) {
+	a.waitFlush()
+
+	events, err := a.priorityEvents()
+	if err != nil {
+		return errors.Wrap(err, "reading priority events")
+	}
+
+	if len(events) == 0 || len(events) < a.PriorityFlushSize {
+		return nil
+	}
+
+	ok, unlockFlush, err := a.tryLockFlush()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		a.Log.Debug("flush in progress by another process")
+		return nil
+	}
+
+	defer unlockFlush()
+
+	snapshot := a.FlushClearMode == ClearRemove
+
+	if !snapshot {
+		unlock, err := a.lockBuffer()
+		if err != nil {
+			return err
+		}
+
+		defer unlock()
+	}
+
+	events, err = a.readPriority(snapshot)
+	if err != nil {
+		return errors.Wrap(err, "reading priority events")
+	}
+
+	defer func() {
+		if err == nil || !snapshot {
+			return
+		}
+
+		if err := a.restoreFile(a.priorityPath()); err != nil {
+			a.Log.WithError(err).Error("restoring priority events")
+		}
+	}()
+
+	a.Log.WithField("size", len(events)).Debug("flush priority")
+
+	err = a.deliver(context.Background(), events, snapshot)
+
+	if kept, ok := undelivered(err); ok {
+		a.metrics.Flushed += len(events) - len(kept)
+
+		if err := a.replacePriority(snapshot, kept); err != nil {
+			return errors.Wrap(err, "keeping undelivered events")
+		}
+
+		return err
+	}
+
+	if err != nil {
+		return deliveryError(err)
+	}
+
+	a.metrics.Flushed += len(events)
+	return a.clearPriority(snapshot)
+}
+
+// replacePriority replaces the flushed high priority events with `events`.
+func (a *Analytics) replacePriority(snapshot bool, events []*Event) error {
+	path := a.priorityPath()
+
+	if snapshot {
+		path += ".flushing"
+	}
+
+	return a.replaceFile(path, events)
+}
+
+// clearPriority removes the flushed high priority events, which
+// are the snapshot in ~/<dir>/priority.flushing when `snapshot` is true.
+func (a *Analytics) clearPriority(snapshot bool) error {
+	path := a.priorityPath()
+
+	if snapshot {
+		path += ".flushing"
+	}
+
+	err := os.Remove(path)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
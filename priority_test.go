@@ -0,0 +1,103 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestTrackPriority(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".priority", Endpoint: s.URL})
+	a.Touch()
+	a.Track("normal", nil)
+	a.TrackPriority("err", nil, PriorityHigh)
+
+	if err := a.ConditionalFlush(10, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := s.Messages(); len(m) != 1 || m[0]["event"] != "err" {
+		t.Fatalf("expected only the high priority event flushed, got %v", m)
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".priority", Endpoint: s.URL})
+
+	if n, _ := b.Size(); n != 1 {
+		t.Fatalf("expected the normal event kept, got %d", n)
+	}
+
+	b.TrackPriority("err2", nil, PriorityHigh)
+
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.Messages()); n != 3 {
+		t.Fatalf("expected Flush to send both buffers, got %d messages", n)
+	}
+
+	if e, _ := b.PriorityEvents(); len(e) != 0 {
+		t.Fatalf("expected the priority buffer emptied, got %v", e)
+	}
+}
+
+func TestTrackPriority_duringFlush(t *testing.T) {
+	home := testHome(t)
+	path := filepath.Join(home, ".priority", "priority")
+
+	for _, conditional := range []bool{false, true} {
+		var sent []string
+		appended := false
+
+		tr := TransportFunc(func(_ string, events []*Event) error {
+			for _, e := range events {
+				sent = append(sent, e.Event)
+			}
+
+			// another process tracking during the flush
+			if !appended {
+				appended = true
+				f, _ := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+				f.WriteString(`{"event":"during","priority":1,"timestamp":"2020-01-01T00:00:00Z"}` + "\n")
+				f.Close()
+			}
+
+			return nil
+		})
+
+		a := New(&Config{WriteKey: "k", Dir: ".priority", Transport: tr})
+		a.Touch()
+		a.TrackPriority("before", nil, PriorityHigh)
+
+		var err error
+
+		if conditional {
+			err = a.ConditionalFlush(10, time.Hour)
+		} else {
+			err = a.Flush()
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(sent) != 1 || sent[0] != "before" {
+			t.Fatalf("expected only the buffered event sent, got %v", sent)
+		}
+
+		b := New(&Config{WriteKey: "k", Dir: ".priority", Transport: tr})
+
+		if e, _ := b.PriorityEvents(); len(e) != 1 || e[0].Event != "during" {
+			t.Fatalf("expected the event tracked during the flush kept, got %v", e)
+		}
+
+		b.Reset()
+	}
+}
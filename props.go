@@ -0,0 +1,85 @@
+package analytics
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPropertiesTooLarge is returned by Track when the properties exceed
+// MaxProperties or MaxPropertyBytes, unless TruncateProperties is set.
+var ErrPropertiesTooLarge = errors.New("properties too large")
+
+// limitProperties enforces MaxProperties and MaxPropertyBytes on `props`.
+// When TruncateProperties is set the keys beyond MaxProperties in sorted
+// order are removed, followed by the largest values until the encoded
+// properties are within MaxPropertyBytes.
+func (a *Analytics) limitProperties(props map[string]interface{}) (map[string]interface{}, error) {
+	if a.MaxProperties > 0 && len(props) > a.MaxProperties {
+		if !a.TruncateProperties {
+			return nil, errors.Wrapf(ErrPropertiesTooLarge, "%d properties exceeds %d", len(props), a.MaxProperties)
+		}
+
+		keys := make([]string, 0, len(props))
+		for k := range props {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		v := make(map[string]interface{}, a.MaxProperties)
+		for _, k := range keys[:a.MaxProperties] {
+			v[k] = props[k]
+		}
+
+		a.Log.WithField("removed", len(keys)-a.MaxProperties).Debug("truncated properties")
+		props = v
+	}
+
+	if a.MaxPropertyBytes <= 0 || len(props) == 0 {
+		return props, nil
+	}
+
+	b, err := json.Marshal(props)
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding properties")
+	}
+
+	if len(b) <= a.MaxPropertyBytes {
+		return props, nil
+	}
+
+	if !a.TruncateProperties {
+		return nil, errors.Wrapf(ErrPropertiesTooLarge, "%d bytes exceeds %d", len(b), a.MaxPropertyBytes)
+	}
+
+	sizes := make(map[string]int, len(props))
+	keys := make([]string, 0, len(props))
+	v := make(map[string]interface{}, len(props))
+
+	for k, val := range props {
+		b, _ := json.Marshal(val)
+		sizes[k] = len(b)
+		keys = append(keys, k)
+		v[k] = val
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if sizes[keys[i]] != sizes[keys[j]] {
+			return sizes[keys[i]] > sizes[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, k := range keys {
+		delete(v, k)
+		a.Log.WithField("key", k).WithField("bytes", sizes[k]).Debug("removed oversized property")
+
+		b, _ := json.Marshal(v)
+		if len(b) <= a.MaxPropertyBytes {
+			break
+		}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,51 @@
+package analytics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestPropertyLimits(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".props", MaxPropertyBytes: 100, MaxProperties: 2})
+
+	if err := a.Track("big", map[string]interface{}{"v": strings.Repeat("x", 200)}); !errors.Is(err, ErrPropertiesTooLarge) {
+		t.Fatalf("expected ErrPropertiesTooLarge for bytes, got %v", err)
+	}
+
+	if err := a.Track("many", map[string]interface{}{"a": 1, "b": 2, "c": 3}); !errors.Is(err, ErrPropertiesTooLarge) {
+		t.Fatalf("expected ErrPropertiesTooLarge for count, got %v", err)
+	}
+
+	if err := a.Track("ok", map[string]interface{}{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := a.Size(); n != 1 {
+		t.Fatalf("expected only the valid event, got %d", n)
+	}
+}
+
+func TestPropertyLimits_truncate(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".props", MaxPropertyBytes: 100, MaxProperties: 2, TruncateProperties: true})
+
+	a.Track("big", map[string]interface{}{"v": strings.Repeat("x", 200), "w": 1})
+	a.Track("many", map[string]interface{}{"a": 1, "b": 2, "c": 3})
+
+	events, _ := a.Events()
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 truncated events, got %d", len(events))
+	}
+
+	if p := events[0].Properties; p["v"] != nil || p["w"] == nil {
+		t.Fatalf("expected the large property removed, got %v", p)
+	}
+
+	if p := events[1].Properties; len(p) != 2 || p["c"] != nil {
+		t.Fatalf("expected the last property removed, got %v", p)
+	}
+}
@@ -0,0 +1,135 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPruned is passed to OnDrop for the events of rotated files removed by Prune.
+var ErrPruned = errors.New("pruned")
+
+// Prune removes artifacts from ~/<dir> until its files total at most
+// `maxBytes`, starting with the temporary files left by interrupted
+// writes, followed by the rotated events files oldest first, whose events
+// are dropped. The events file, id and other state are never removed, so
+// the total may remain above `maxBytes`. It's a no-op while another
+// process is flushing.
+func (a *Analytics) Prune(maxBytes int64) error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	if a.err != nil {
+		return a.err
+	}
+
+	return storageError(a.prune(maxBytes))
+}
+
+// prune implementation.
+func (a *Analytics) prune(maxBytes int64) error {
+	ok, unlockFlush, err := a.tryLockFlush()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		a.Log.Debug("flush in progress, skipping prune")
+		return nil
+	}
+
+	defer unlockFlush()
+
+	unlock, err := a.lockBuffer()
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	infos, err := ioutil.ReadDir(a.root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "reading dir")
+	}
+
+	var total int64
+	var temps []os.FileInfo
+
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		total += info.Size()
+
+		if strings.HasSuffix(info.Name(), ".tmp") {
+			temps = append(temps, info)
+		}
+	}
+
+	if total <= maxBytes {
+		return nil
+	}
+
+	sort.Slice(temps, func(i, j int) bool {
+		return temps[i].ModTime().Before(temps[j].ModTime())
+	})
+
+	var paths []string
+
+	for _, info := range temps {
+		paths = append(paths, filepath.Join(a.root, info.Name()))
+	}
+
+	rotated, err := a.rotated()
+	if err != nil {
+		return errors.Wrap(err, "listing rotated events")
+	}
+
+	paths = append(paths, rotated...)
+
+	for _, path := range paths {
+		if total <= maxBytes {
+			break
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		if rotatedNumber(path) > 0 {
+			events, err := a.readRotated([]string{path})
+			if err != nil {
+				a.Log.WithError(err).Debug("error reading pruned events")
+			}
+
+			for _, e := range events {
+				a.drop(e, ErrPruned)
+			}
+
+			a.countSize = -1
+		}
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "removing %s", filepath.Base(path))
+		}
+
+		a.Log.WithField("file", filepath.Base(path)).WithField("bytes", info.Size()).Debug("pruned")
+		total -= info.Size()
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package analytics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// usage returns the bytes used by the regular files in dir.
+func usage(dir string) (n int64) {
+	infos, _ := ioutil.ReadDir(dir)
+
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			n += info.Size()
+		}
+	}
+
+	return
+}
+
+func TestPrune(t *testing.T) {
+	home := testHome(t)
+	dir := filepath.Join(home, ".prune")
+	var dropped int
+
+	a := New(&Config{WriteKey: "k", Dir: ".prune", MaxBytes: 200, OnDrop: func(e *Event, err error) {
+		if err == ErrPruned {
+			dropped++
+		}
+	}})
+
+	for i := 0; i < 20; i++ {
+		a.Track("event", map[string]interface{}{"i": i})
+	}
+
+	rotated, _ := a.rotated()
+
+	if len(rotated) < 3 {
+		t.Fatalf("expected rotated files, got %v", rotated)
+	}
+
+	big := bytes.Repeat([]byte("x"), 4096)
+	ioutil.WriteFile(filepath.Join(dir, "events.tmp"), big, 0600)
+	ioutil.WriteFile(filepath.Join(dir, "analytics.json.tmp"), big, 0600)
+
+	limit := usage(dir) - 8192 - 100
+
+	if err := a.Prune(limit); err != nil {
+		t.Fatal(err)
+	}
+
+	if u := usage(dir); u > limit {
+		t.Fatalf("expected usage under %d, got %d", limit, u)
+	}
+
+	if dropped == 0 {
+		t.Fatal("expected pruned events reported to OnDrop")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "events.tmp")); !os.IsNotExist(err) {
+		t.Fatalf("expected temp files removed first, got %v", err)
+	}
+
+	after, _ := a.rotated()
+
+	if len(after) >= len(rotated) || (len(after) > 0 && after[0] == rotated[0]) {
+		t.Fatalf("expected the oldest rotated files removed, got %v from %v", after, rotated)
+	}
+}
+
+func TestPrune_zero(t *testing.T) {
+	home := testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".prune"})
+	a.Track("x", nil)
+
+	if err := a.Prune(0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"id", "events"} {
+		if _, err := os.Stat(filepath.Join(home, ".prune", name)); err != nil {
+			t.Fatalf("expected %s kept, got %v", name, err)
+		}
+	}
+}
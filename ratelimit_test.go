@@ -0,0 +1,156 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+// sleepClock is a fakeClock which advances when slept on, optionally
+// signalling and blocking each sleep.
+type sleepClock struct {
+	fakeClock
+	sleeping chan struct{}
+	wake     chan struct{}
+}
+
+func (c *sleepClock) Sleep(d time.Duration) {
+	if c.sleeping != nil {
+		c.sleeping <- struct{}{}
+		<-c.wake
+	}
+
+	c.t = c.t.Add(d)
+}
+
+func TestMaxTrackRate(t *testing.T) {
+	testHome(t)
+	c := &fakeClock{t: time.Unix(1000, 0)}
+	a := New(&Config{WriteKey: "k", Dir: ".rl", MaxTrackRate: 5, Clock: c})
+
+	for i := 0; i < 100; i++ {
+		a.Track("x", nil)
+	}
+
+	if n, _ := a.Size(); n != 5 {
+		t.Fatalf("expected a burst of 5 events, got %d", n)
+	}
+
+	c.t = c.t.Add(time.Second)
+
+	for i := 0; i < 100; i++ {
+		a.Track("x", nil)
+	}
+
+	if n, _ := a.Size(); n != 10 {
+		t.Fatalf("expected 5 more events, got %d", n)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	testHome(t)
+	c := &fakeClock{t: time.Unix(1000, 0)}
+	a := New(&Config{WriteKey: "k", Dir: ".rl", RateLimit: 5, Clock: c})
+
+	for i := 0; i < 100; i++ {
+		a.Track("x", nil)
+	}
+
+	if n, _ := a.Size(); n != 5 {
+		t.Fatalf("expected a burst of 5 events, got %d", n)
+	}
+}
+
+func TestBlockOnRateLimit(t *testing.T) {
+	testHome(t)
+	c := &sleepClock{fakeClock: fakeClock{t: time.Unix(1000, 0)}}
+	a := New(&Config{WriteKey: "k", Dir: ".rl", MaxTrackRate: 1, BlockOnRateLimit: true, Clock: c})
+
+	for i := 0; i < 3; i++ {
+		a.Track("x", nil)
+	}
+
+	if n, _ := a.Size(); n != 3 {
+		t.Fatalf("expected all events to be tracked, got %d", n)
+	}
+
+	if d := c.t.Sub(time.Unix(1000, 0)); d != 2*time.Second {
+		t.Fatalf("expected to wait 2s on the clock, got %s", d)
+	}
+}
+
+func TestBlockOnRateLimit_unlocked(t *testing.T) {
+	testHome(t)
+
+	c := &sleepClock{
+		fakeClock: fakeClock{t: time.Unix(1000, 0)},
+		sleeping:  make(chan struct{}),
+		wake:      make(chan struct{}),
+	}
+
+	a := New(&Config{WriteKey: "k", Dir: ".rl", MaxTrackRate: 1, BlockOnRateLimit: true, Clock: c})
+	a.Track("x", nil)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		a.Track("y", nil)
+	}()
+
+	<-c.sleeping
+
+	within(t, 2*time.Second, func() {
+		a.Size()
+	})
+
+	close(c.wake)
+	<-done
+
+	if n, _ := a.Size(); n != 2 {
+		t.Fatalf("expected both events, got %d", n)
+	}
+}
+
+func TestBlockOnRateLimit_stoppedClock(t *testing.T) {
+	testHome(t)
+	var dropped error
+
+	a := New(&Config{
+		WriteKey:         "k",
+		Dir:              ".rl",
+		MaxTrackRate:     100,
+		BlockOnRateLimit: true,
+		Clock:            &fakeClock{t: time.Unix(1000, 0)},
+		OnDrop:           func(e *Event, err error) { dropped = err },
+	})
+
+	within(t, 2*time.Second, func() {
+		for i := 0; i < 101; i++ {
+			a.Track("x", nil)
+		}
+	})
+
+	if dropped != ErrRateLimited {
+		t.Fatalf("expected ErrRateLimited, got %v", dropped)
+	}
+}
+
+func TestMaxTrackRate_clock(t *testing.T) {
+	testHome(t)
+	c := &fakeClock{t: time.Unix(1000, 0)}
+	a := New(&Config{WriteKey: "k", Dir: ".rl", MaxTrackRate: 50, Clock: c})
+
+	for i := 0; i < 100; i++ {
+		a.Track("x", nil)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		a.Track("x", nil)
+	}
+
+	if n, _ := a.Size(); n != 50 {
+		t.Fatalf("expected the limit refilled only by the Clock, got %d", n)
+	}
+}
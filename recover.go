@@ -0,0 +1,86 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Recover merges the events left in ~/<dir>/events.flushing and
+// ~/<dir>/priority.flushing by an interrupted flush back into their buffers,
+// and removes the temporary files left by an interrupted rewrite, whose events
+// remain in the file being rewritten. It's a no-op while another process is
+// flushing, or when tracking is disabled.
+func (a *Analytics) Recover() error {
+	if a.noop {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.unlock()
+
+	if a.err != nil {
+		return a.err
+	}
+
+	if a.disabled() || a.store != nil {
+		return nil
+	}
+
+	return storageError(a.recover())
+}
+
+// recover implementation.
+func (a *Analytics) recover() error {
+	ok, unlock, err := a.tryLockFlush()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		a.Log.Debug("flush in progress, skipping recovery")
+		return nil
+	}
+
+	defer unlock()
+
+	for _, name := range []string{"events.tmp", "events.flushing.tmp", "priority.tmp", "priority.flushing.tmp"} {
+		err := os.Remove(filepath.Join(a.root, name))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "removing %s", name)
+		}
+	}
+
+	if err := a.restoreFile(a.priorityPath()); err != nil {
+		return errors.Wrap(err, "recovering priority events")
+	}
+
+	path := filepath.Join(a.root, "events.flushing")
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "opening")
+	}
+
+	events, err := a.decodeAll(f)
+	f.Close()
+
+	if err != nil {
+		return errors.Wrap(err, "reading leftover events")
+	}
+
+	a.Log.WithField("count", len(events)).Debug("recovering events")
+
+	for _, e := range events {
+		if err := a.write(e); err != nil {
+			return errors.Wrap(err, "writing")
+		}
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,43 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	home := testHome(t)
+	dir := filepath.Join(home, ".recover")
+
+	a := New(&Config{WriteKey: "k", Dir: ".recover"})
+	a.Track("old1", nil)
+	a.Track("old2", nil)
+	a.closeEvents()
+
+	os.Rename(filepath.Join(dir, "events"), filepath.Join(dir, "events.flushing"))
+	ioutil.WriteFile(filepath.Join(dir, "events.tmp"), []byte("{}\n"), 0600)
+
+	b := New(&Config{WriteKey: "k", Dir: ".recover", AutoRecover: true})
+	b.Track("new", nil)
+	events, err := b.Events()
+
+	if err != nil || len(events) != 3 || events[0].Event != "old1" || events[2].Event != "new" {
+		t.Fatalf("expected the interrupted events merged first, got %v and %v", events, err)
+	}
+
+	for _, name := range []string{"events.flushing", "events.tmp"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s removed, got %v", name, err)
+		}
+	}
+
+	if err := b.Recover(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := b.Size(); n != 3 {
+		t.Fatalf("expected Recover to be a no-op, got %d", n)
+	}
+}
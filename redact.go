@@ -0,0 +1,37 @@
+package analytics
+
+import "regexp"
+
+// Redacted is the value substituted for redacted properties.
+const Redacted = "[redacted]"
+
+// RedactKeys returns a Redact func replacing the values of properties with
+// keys matching `re` by Redacted, including the keys of nested maps.
+func RedactKeys(re *regexp.Regexp) func(map[string]interface{}) map[string]interface{} {
+	var redact func(map[string]interface{}) map[string]interface{}
+
+	redact = func(props map[string]interface{}) map[string]interface{} {
+		if props == nil {
+			return nil
+		}
+
+		v := make(map[string]interface{}, len(props))
+
+		for k, val := range props {
+			if re.MatchString(k) {
+				v[k] = Redacted
+				continue
+			}
+
+			if m, ok := val.(map[string]interface{}); ok {
+				val = redact(m)
+			}
+
+			v[k] = val
+		}
+
+		return v
+	}
+
+	return redact
+}
@@ -0,0 +1,48 @@
+package analytics
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRedactKeys(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".red", Redact: RedactKeys(regexp.MustCompile(`(?i)path`))})
+
+	a.Track("x", map[string]interface{}{
+		"path":   "/home/bob",
+		"nested": map[string]interface{}{"FilePath": "/tmp", "ok": 1},
+		"keep":   2,
+	})
+
+	events, _ := a.Events()
+	p := events[0].Properties
+
+	if p["path"] != Redacted || p["keep"] != 2.0 {
+		t.Fatalf("expected the matching keys redacted, got %v", p)
+	}
+
+	if n := p["nested"].(map[string]interface{}); n["FilePath"] != Redacted || n["ok"] != 1.0 {
+		t.Fatalf("expected the nested keys redacted, got %v", n)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{
+		WriteKey: "k",
+		Dir:      ".red",
+		Redact: func(m map[string]interface{}) map[string]interface{} {
+			delete(m, "secret")
+			return m
+		},
+	})
+
+	a.Track("x", map[string]interface{}{"secret": 1, "other": 2})
+	events, _ := a.Events()
+
+	if _, ok := events[0].Properties["secret"]; ok {
+		t.Fatalf("expected the property removed, got %v", events[0].Properties)
+	}
+}
@@ -0,0 +1,41 @@
+package analytics
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRejectedError(t *testing.T) {
+	testHome(t)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b struct {
+			Batch []map[string]interface{} `json:"batch"`
+		}
+
+		json.NewDecoder(r.Body).Decode(&b)
+		fmt.Fprintf(w, `{"errors":[{"messageId":%q}]}`, b.Batch[1]["messageId"])
+	}))
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".reject", Endpoint: s.URL, DirectHTTP: true, EventsHeader: true})
+	a.Track("A", nil)
+	a.Track("B", nil)
+	a.Track("C", nil)
+
+	err := a.Flush()
+	rerr, ok := err.(*RejectedError)
+
+	if !ok || len(rerr.IDs) != 1 {
+		t.Fatalf("expected 1 rejected id, got %v", err)
+	}
+
+	events, err := a.Events()
+
+	if err != nil || len(events) != 1 || events[0].Event != "B" {
+		t.Fatalf("expected only the rejected event retained, got %v and %v", events, err)
+	}
+}
@@ -0,0 +1,38 @@
+package analytics
+
+import "testing"
+
+func TestReset(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".reset"})
+	a.Track("x", nil)
+	a.Track("y", nil)
+	prev := a.anonymousID
+
+	if err := a.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := a.Size(); n != 0 || err != nil {
+		t.Fatalf("expected no events, got %d and %v", n, err)
+	}
+
+	if a.anonymousID == prev {
+		t.Fatal("expected a new id")
+	}
+
+	if err := a.Track("z", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := a.Size(); n != 1 {
+		t.Fatalf("expected tracking to continue, got %d", n)
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".reset"})
+
+	if b.anonymousID != a.anonymousID {
+		t.Fatalf("expected the new id persisted, got %q", b.anonymousID)
+	}
+}
@@ -0,0 +1,244 @@
+package analytics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+// failRT fails the first `fail` requests, asserting
+// that the events are still buffered.
+type failRT struct {
+	n    int
+	fail int
+	path string
+	t    *testing.T
+}
+
+func (f *failRT) RoundTrip(r *http.Request) (*http.Response, error) {
+	if _, err := os.Stat(f.path); err != nil {
+		f.t.Error("expected the events to be buffered until delivered")
+	}
+
+	f.n++
+	if f.n <= f.fail {
+		return nil, os.ErrDeadlineExceeded
+	}
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestFlushRetries(t *testing.T) {
+	home := testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	rt := &failRT{fail: 2, path: filepath.Join(home, ".retry", "events.flushing"), t: t}
+
+	a := New(&Config{
+		WriteKey:     "k",
+		Dir:          ".retry",
+		Endpoint:     s.URL,
+		FlushRetries: 2,
+		FlushBackoff: time.Millisecond,
+		HTTPClient:   &http.Client{Transport: rt},
+	})
+
+	a.Track("x", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if rt.n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", rt.n)
+	}
+
+	if n := len(s.Messages()); n != 1 {
+		t.Fatalf("expected 1 message, got %d", n)
+	}
+}
+
+func TestFlushRetries_exhausted(t *testing.T) {
+	home := testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	rt := &failRT{fail: 2, path: filepath.Join(home, ".retry", "events.flushing"), t: t}
+
+	a := New(&Config{
+		WriteKey:     "k",
+		Dir:          ".retry",
+		Endpoint:     s.URL,
+		FlushRetries: 1,
+		FlushBackoff: time.Millisecond,
+		HTTPClient:   &http.Client{Transport: rt},
+	})
+
+	a.Track("x", nil)
+
+	if err := a.Flush(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if n, _ := a.Size(); n != 1 {
+		t.Fatalf("expected the event to be kept, got %d", n)
+	}
+}
+
+func TestFlushRetries_status(t *testing.T) {
+	cases := []struct {
+		status   int
+		attempts int
+	}{
+		{http.StatusUnauthorized, 1},
+		{http.StatusBadRequest, 1},
+		{http.StatusTooManyRequests, 3},
+		{http.StatusServiceUnavailable, 3},
+	}
+
+	for _, direct := range []bool{false, true} {
+		for _, c := range cases {
+			testHome(t)
+			var n int
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n++
+				w.WriteHeader(c.status)
+			}))
+
+			a := New(&Config{
+				WriteKey:     "k",
+				Dir:          ".retry",
+				Endpoint:     s.URL,
+				DirectHTTP:   direct,
+				FlushRetries: 2,
+				FlushBackoff: time.Millisecond,
+			})
+
+			a.Track("x", nil)
+			err := a.Flush()
+			s.Close()
+
+			var serr *StatusError
+			if !errors.As(err, &serr) || serr.Code != c.status {
+				t.Fatalf("expected a *StatusError for %d, got %v", c.status, err)
+			}
+
+			if n != c.attempts {
+				t.Fatalf("expected %d attempts for %d, got %d", c.attempts, c.status, n)
+			}
+
+			if size, _ := a.Size(); size != 1 {
+				t.Fatalf("expected the event to be kept for %d, got %d", c.status, size)
+			}
+		}
+	}
+}
+
+func TestFlushRetries_backoff(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{err: errors.New("boom")}
+
+	a := New(&Config{
+		WriteKey:     "k",
+		Dir:          ".retry",
+		Transport:    tr,
+		FlushRetries: 3,
+		FlushBackoff: 20 * time.Millisecond,
+	})
+
+	a.Track("x", nil)
+	start := time.Now()
+
+	if err := a.Flush(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if tr.sends != 4 {
+		t.Fatalf("expected 4 attempts, got %d", tr.sends)
+	}
+
+	if d := time.Since(start); d < 140*time.Millisecond {
+		t.Fatalf("expected backoffs of 20ms, 40ms and 80ms, took %s", d)
+	}
+}
+
+func TestFlushRetries_clock(t *testing.T) {
+	testHome(t)
+	c := &sleepClock{fakeClock: fakeClock{t: time.Unix(1000, 0)}}
+	tr := &countTransport{err: errors.New("boom")}
+
+	a := New(&Config{
+		WriteKey:     "k",
+		Dir:          ".retry",
+		Transport:    tr,
+		Clock:        c,
+		FlushRetries: 2,
+		FlushBackoff: time.Hour,
+	})
+
+	a.Track("x", nil)
+
+	within(t, time.Second, func() {
+		if err := a.Flush(); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	if tr.sends != 3 {
+		t.Fatalf("expected 3 attempts, got %d", tr.sends)
+	}
+
+	if d := c.Now().Sub(time.Unix(1000, 0)); d != 3*time.Hour {
+		t.Fatalf("expected backoffs of 1h and 2h on the clock, got %s", d)
+	}
+}
+
+func TestFlush_trackDuringDelivery(t *testing.T) {
+	testHome(t)
+	sending := make(chan struct{})
+	release := make(chan struct{})
+
+	a := New(&Config{
+		WriteKey: "k",
+		Dir:      ".retry",
+		Transport: TransportFunc(func(userID string, events []*Event) error {
+			close(sending)
+			<-release
+			return nil
+		}),
+	})
+
+	a.Track("x", nil)
+
+	done := make(chan error)
+	go func() { done <- a.Flush() }()
+	<-sending
+
+	within(t, time.Second, func() {
+		a.Track("y", nil)
+	})
+
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	a = New(&Config{WriteKey: "k", Dir: ".retry"})
+	events, err := a.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0].Event != "y" {
+		t.Fatalf("expected only the event tracked during delivery to be kept, got %v", events)
+	}
+}
@@ -0,0 +1,167 @@
+package analytics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rotated returns the paths of the rotated events files,
+// ~/<dir>/events.<n>, oldest first.
+func (a *Analytics) rotated() ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(a.root, "events.*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+
+	for _, path := range paths {
+		if rotatedNumber(path) > 0 {
+			files = append(files, path)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return rotatedNumber(files[i]) < rotatedNumber(files[j])
+	})
+
+	return files, nil
+}
+
+// rotatedNumber returns the number of rotated file `path`, or zero
+// for other files such as events.flushing.
+func rotatedNumber(path string) int {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+
+	n, err := strconv.Atoi(ext)
+	if err != nil || n < 0 {
+		return 0
+	}
+
+	return n
+}
+
+// rotate moves the events file to the next events.<n> once it
+// reaches MaxBytes, and opens a fresh events file.
+func (a *Analytics) rotate() error {
+	if a.MaxBytes <= 0 || a.eventsFile == nil {
+		return nil
+	}
+
+	info, err := a.eventsFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() < a.MaxBytes {
+		return nil
+	}
+
+	files, err := a.rotated()
+	if err != nil {
+		return err
+	}
+
+	n := 1
+	if len(files) > 0 {
+		n = rotatedNumber(files[len(files)-1]) + 1
+	}
+
+	path := filepath.Join(a.root, "events")
+	a.Log.WithField("n", n).Debug("rotating events")
+
+	a.closeEvents()
+	a.last = nil
+	err = os.Rename(path, fmt.Sprintf("%s.%d", path, n))
+
+	if err := a.openEvents(); err != nil {
+		return err
+	}
+
+	return err
+}
+
+// readRotated returns the events of `files`.
+func (a *Analytics) readRotated(files []string) ([]*Event, error) {
+	var events []*Event
+
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := a.decodeAll(f)
+		f.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, v...)
+	}
+
+	return events, nil
+}
+
+// removeRotated removes `files`, ignoring those already removed.
+func removeRotated(files []string) error {
+	for _, path := range files {
+		err := os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// bufferSize returns the total size of the events file and rotated files.
+func (a *Analytics) bufferSize() (int64, error) {
+	files, err := a.rotated()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+
+	for _, path := range append(files, filepath.Join(a.root, "events")) {
+		info, err := os.Stat(path)
+
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		size += info.Size()
+	}
+
+	return size, nil
+}
+
+// rotatedReader reads the files followed by the events file.
+type rotatedReader struct {
+	io.Reader
+	files []*os.File
+}
+
+// Close implementation.
+func (r *rotatedReader) Close() error {
+	var err error
+
+	for _, f := range r.files {
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMaxBytes_rotate(t *testing.T) {
+	for _, mode := range []ClearMode{ClearRemove, ClearTruncate} {
+		home := testHome(t)
+		tr := &recordTransport{}
+
+		a := New(&Config{WriteKey: "k", Dir: ".rot", MaxBytes: 300, Transport: tr, FlushClearMode: mode})
+
+		for i := 0; i < 20; i++ {
+			a.Track("x", map[string]interface{}{"i": i})
+		}
+
+		rotated, _ := filepath.Glob(filepath.Join(home, ".rot", "events.*"))
+
+		if len(rotated) < 3 {
+			t.Fatalf("expected the events file to be rotated, got %v", rotated)
+		}
+
+		events, err := a.Events()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(events) != 20 {
+			t.Fatalf("expected 20 events, got %d", len(events))
+		}
+
+		for i, e := range events {
+			if e.Properties["i"] != float64(i) {
+				t.Fatalf("expected the events in order, got %v at %d", e.Properties["i"], i)
+			}
+		}
+
+		b := New(&Config{WriteKey: "k", Dir: ".rot", MaxBytes: 300})
+
+		if n, _ := b.Size(); n != 20 {
+			t.Fatalf("expected another process to count the rotated events, got %d", n)
+		}
+
+		b.Close()
+
+		if err := a.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(tr.events) != 20 {
+			t.Fatalf("expected 20 events delivered, got %d", len(tr.events))
+		}
+
+		for i, e := range tr.events {
+			if e.Properties["i"] != float64(i) {
+				t.Fatalf("expected the events delivered in order, got %v at %d", e.Properties["i"], i)
+			}
+		}
+
+		rotated, _ = filepath.Glob(filepath.Join(home, ".rot", "events.*"))
+
+		if len(rotated) != 0 {
+			t.Fatalf("expected the rotated files removed, got %v", rotated)
+		}
+	}
+}
+
+func TestMaxBytes_rotateFailedFlush(t *testing.T) {
+	home := testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".rot", MaxBytes: 200, Transport: failTransport{}})
+
+	for i := 0; i < 10; i++ {
+		a.Track("x", nil)
+	}
+
+	if err := a.Flush(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".rot", MaxBytes: 200})
+
+	if n, _ := b.Size(); n != 10 {
+		t.Fatalf("expected the events kept, got %d", n)
+	}
+
+	if err := b.Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if rotated, _ := filepath.Glob(filepath.Join(home, ".rot", "events.*")); len(rotated) != 0 {
+		t.Fatalf("expected Reset to remove the rotated files, got %v", rotated)
+	}
+}
@@ -0,0 +1,56 @@
+package analytics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotateID(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".rotateid"})
+	prev := a.anonymousID
+
+	id, err := a.RotateID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id == "" || id == prev || a.anonymousID != id {
+		t.Fatalf("expected a new id, got %q from %q", id, prev)
+	}
+
+	events, err := a.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0].Type != TypeAlias || events[0].PreviousID != prev {
+		t.Fatalf("expected an alias from the previous id, got %v", events)
+	}
+}
+
+func TestRotateID_disabled(t *testing.T) {
+	home := testHome(t)
+	t.Setenv("DO_NOT_TRACK", "1")
+
+	a := New(&Config{WriteKey: "k", Dir: ".rotateid"})
+	path := filepath.Join(home, ".rotateid", "id")
+	before, _ := ioutil.ReadFile(path)
+
+	id, err := a.RotateID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != "" {
+		t.Fatalf("expected no id when disabled, got %q", id)
+	}
+
+	after, _ := ioutil.ReadFile(path)
+
+	if !bytes.Equal(before, after) {
+		t.Fatalf("expected the id untouched when disabled, got %q from %q", after, before)
+	}
+}
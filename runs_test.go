@@ -0,0 +1,70 @@
+package analytics
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFirstRun(t *testing.T) {
+	testHome(t)
+	var n int
+
+	a := New(&Config{WriteKey: "k", Dir: ".runs", OnFirstRun: func() { n++ }})
+
+	if !a.FirstRun() || n != 1 {
+		t.Fatalf("expected the first run, got %v and %d calls", a.FirstRun(), n)
+	}
+
+	a.Close()
+
+	b := New(&Config{WriteKey: "k", Dir: ".runs", OnFirstRun: func() { n++ }})
+
+	if b.FirstRun() || n != 1 {
+		t.Fatalf("expected a subsequent run, got %v and %d calls", b.FirstRun(), n)
+	}
+}
+
+func TestRunCount(t *testing.T) {
+	testHome(t)
+	config := &Config{WriteKey: "k", Dir: ".runs", FlushEveryNRuns: 10, Transport: &countTransport{}}
+
+	New(config).Close()
+	New(config).Close()
+	a := New(config)
+
+	if n := a.RunCount(); n != 3 {
+		t.Fatalf("expected 3 runs, got %d", n)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			a.RunCount()
+			a.FirstRun()
+		}
+	}()
+
+	a.Track("x", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	wg.Wait()
+
+	if n := a.RunCount(); n != 0 {
+		t.Fatalf("expected the runs to be reset, got %d", n)
+	}
+}
+
+func TestIsFirstRun(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".runs"})
+
+	if a.IsFirstRun() != a.FirstRun() {
+		t.Fatal("expected IsFirstRun to alias FirstRun")
+	}
+}
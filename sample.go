@@ -0,0 +1,36 @@
+package analytics
+
+import (
+	"math/rand"
+	"time"
+)
+
+// sampled returns true if an event should be kept with respect to
+// SampleRate. Sampling is per-event and best-effort, so the number of
+// events kept only approaches the rate over many events.
+func (a *Analytics) sampled() bool {
+	if a.SampleRate == nil || *a.SampleRate >= 1 {
+		return true
+	}
+
+	return a.rng().Float64() < *a.SampleRate
+}
+
+// jittered returns `d` shifted randomly by up to FlushJitter either way.
+func (a *Analytics) jittered(d time.Duration) time.Duration {
+	if a.FlushJitter <= 0 {
+		return d
+	}
+
+	n := a.rng().Int63n(2*int64(a.FlushJitter) + 1)
+	return d + time.Duration(n) - a.FlushJitter
+}
+
+// rng returns the random source, created from RandSource.
+func (a *Analytics) rng() *rand.Rand {
+	if a.random == nil {
+		a.random = rand.New(a.RandSource)
+	}
+
+	return a.random
+}
@@ -0,0 +1,65 @@
+package analytics
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSampleRate(t *testing.T) {
+	testHome(t)
+	var dropped int
+
+	a := New(&Config{
+		WriteKey:   "k",
+		Dir:        ".smp",
+		RandSource: rand.NewSource(1),
+		OnDrop: func(e *Event, err error) {
+			if err == ErrSampled {
+				dropped++
+			}
+		},
+	}, WithSampleRate(0.25))
+
+	for i := 0; i < 4000; i++ {
+		a.Track("x", nil)
+	}
+
+	n, _ := a.Size()
+
+	if n < 900 || n > 1100 || n+dropped != 4000 {
+		t.Fatalf("expected about a quarter kept, got %d and %d dropped", n, dropped)
+	}
+}
+
+func TestSampleRate_bounds(t *testing.T) {
+	cases := []struct {
+		name string
+		rate *float64
+		kept int
+	}{
+		{"unset", nil, 10},
+		{"zero", new(float64), 0},
+	}
+
+	for _, c := range cases {
+		testHome(t)
+		a := New(&Config{WriteKey: "k", Dir: ".smp", SampleRate: c.rate})
+
+		for i := 0; i < 10; i++ {
+			a.Track("x", nil)
+		}
+
+		if n, _ := a.Size(); n != c.kept {
+			t.Fatalf("%s: expected %d events kept, got %d", c.name, c.kept, n)
+		}
+	}
+}
+
+func TestSampleRate_invalid(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".smp"}, WithSampleRate(1.5))
+
+	if err := a.Validate(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,40 @@
+package analytics
+
+import "testing"
+
+func TestSequenceProperty(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".seq", SequenceProperty: "_seq"})
+	a.Track("a", nil)
+	a.Track("b", nil)
+
+	b := New(&Config{WriteKey: "k", Dir: ".seq", SequenceProperty: "_seq"})
+	b.Track("c", nil)
+	b.Track("d", map[string]interface{}{"x": 1})
+
+	events, _ := b.Events()
+
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+
+	for i, e := range events {
+		if e.Properties["_seq"] != float64(i+1) {
+			t.Fatalf("expected sequence %d across runs, got %v", i+1, e.Properties)
+		}
+	}
+}
+
+func TestSequenceProperty_unset(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".seq"})
+	a.Track("a", nil)
+
+	events, _ := a.Events()
+
+	if _, ok := events[0].Properties["_seq"]; ok {
+		t.Fatalf("expected no sequence property, got %v", events[0].Properties)
+	}
+}
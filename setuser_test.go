@@ -0,0 +1,45 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestSetUserID(t *testing.T) {
+	home := testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".user", Endpoint: s.URL})
+	a.Track("anon", nil)
+	a.Flush()
+
+	b := New(&Config{WriteKey: "k", Dir: ".user", Endpoint: s.URL})
+	b.Track("x", nil)
+
+	if err := b.SetUserID("acct-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, _ := ioutil.ReadFile(filepath.Join(home, ".user", "user_id")); string(s) != "acct-1" {
+		t.Fatalf("expected the user id stored, got %q", s)
+	}
+
+	b.Flush()
+	m := s.Messages()
+
+	if len(m) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(m))
+	}
+
+	if m[0]["userId"] != nil || m[0]["anonymousId"] == nil {
+		t.Fatalf("expected an anonymous message, got %v", m[0])
+	}
+
+	if m[1]["userId"] != "acct-1" || m[1]["anonymousId"] != m[0]["anonymousId"] {
+		t.Fatalf("expected the user id alongside the anonymous id, got %v", m[1])
+	}
+}
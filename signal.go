@@ -0,0 +1,75 @@
+package analytics
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// signalFlushTimeout bounds the flush performed by FlushOnSignal.
+const signalFlushTimeout = 2 * time.Second
+
+// FlushOnSignal flushes when one of `sigs` is received, such as os.Interrupt,
+// then restores the default behavior and re-raises the signal so that the
+// program still exits. The flush is best-effort and bounded to two seconds.
+// The returned stop func removes the handler.
+func (a *Analytics) FlushOnSignal(sigs ...os.Signal) (stop func()) {
+	if a.noop {
+		return func() {}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		a.handleSignal(ch, quit, func(sig os.Signal) {
+			signal.Stop(ch)
+			raise(sig)
+		})
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(quit)
+			<-done
+		})
+	}
+}
+
+// handleSignal waits for a signal on `ch`, flushing and then
+// passing it to `raise`, or returns when `quit` is closed.
+func (a *Analytics) handleSignal(ch <-chan os.Signal, quit <-chan struct{}, raise func(os.Signal)) {
+	select {
+	case sig := <-ch:
+		a.Log.WithField("signal", sig).Debug("flush on signal")
+
+		ctx, cancel := context.WithTimeout(context.Background(), signalFlushTimeout)
+		defer cancel()
+
+		if err := a.FlushContext(ctx); err != nil {
+			a.Log.WithError(err).Debug("error flushing on signal")
+		}
+
+		raise(sig)
+	case <-quit:
+	}
+}
+
+// raise sends `sig` to the current process.
+func raise(sig os.Signal) {
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		return
+	}
+
+	p.Signal(sig)
+}
@@ -0,0 +1,31 @@
+package analytics
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFlushOnSignal(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+	a := New(&Config{WriteKey: "k", Dir: ".sig", Transport: tr})
+	a.Track("x", nil)
+
+	ch := make(chan os.Signal, 1)
+	ch <- os.Interrupt
+
+	var got os.Signal
+	a.handleSignal(ch, nil, func(s os.Signal) { got = s })
+
+	if got != os.Interrupt {
+		t.Fatalf("expected the signal to be re-raised, got %v", got)
+	}
+
+	if tr.n != 1 {
+		t.Fatalf("expected a flush, got %d events", tr.n)
+	}
+
+	stop := a.FlushOnSignal(os.Interrupt)
+	stop()
+	stop()
+}
@@ -0,0 +1,138 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSingleFile(t *testing.T) {
+	home := testHome(t)
+	tr := &countTransport{}
+
+	a := New(&Config{WriteKey: "k", Dir: ".sf", SingleFile: true, Transport: tr})
+
+	if !a.FirstRun() {
+		t.Fatal("expected first run")
+	}
+
+	a.Track("x", nil)
+	a.Track("y", nil)
+
+	b := New(&Config{WriteKey: "k", Dir: ".sf", SingleFile: true, Transport: tr})
+
+	if b.FirstRun() {
+		t.Fatal("expected the state to be shared")
+	}
+
+	if b.anonymousID != a.anonymousID {
+		t.Fatalf("expected id %q, got %q", a.anonymousID, b.anonymousID)
+	}
+
+	events, err := b.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 || events[1].Event != "y" {
+		t.Fatalf("unexpected events %v", events)
+	}
+
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.n != 2 {
+		t.Fatalf("expected 2 events delivered, got %d", tr.n)
+	}
+
+	c := New(&Config{WriteKey: "k", Dir: ".sf", SingleFile: true})
+
+	if n, _ := c.Size(); n != 0 {
+		t.Fatalf("expected an empty buffer, got %d", n)
+	}
+
+	s, err := c.readState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.LastFlush == nil || s.LastFlushSuccess == nil {
+		t.Fatalf("expected the flush times, got %+v", s)
+	}
+
+	c.Disable()
+
+	d := New(&Config{WriteKey: "k", Dir: ".sf", SingleFile: true})
+
+	if ok, _ := d.Enabled(); ok {
+		t.Fatal("expected disabled")
+	}
+
+	files, _ := ioutil.ReadDir(filepath.Join(home, ".sf"))
+
+	for _, f := range files {
+		if f.Name() != "analytics.json" && !strings.HasSuffix(f.Name(), "lock") {
+			t.Fatalf("unexpected file %q", f.Name())
+		}
+	}
+}
+
+func TestSingleFile_trackDuringFlush(t *testing.T) {
+	testHome(t)
+	b := New(&Config{WriteKey: "k", Dir: ".sf", SingleFile: true})
+
+	var sent int
+
+	a := New(&Config{
+		WriteKey:   "k",
+		Dir:        ".sf",
+		SingleFile: true,
+		Transport: TransportFunc(func(_ string, events []*Event) error {
+			sent += len(events)
+			return b.Track("during", nil)
+		}),
+	})
+
+	a.Track("before", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent != 1 {
+		t.Fatalf("expected 1 event delivered, got %d", sent)
+	}
+
+	events, _ := b.Events()
+
+	if len(events) != 1 || events[0].Event != "during" {
+		t.Fatalf("expected the event tracked during the flush, got %v", events)
+	}
+}
+
+func TestSingleFile_flushLock(t *testing.T) {
+	testHome(t)
+	tr := &countTransport{}
+	b := New(&Config{WriteKey: "k", Dir: ".sf", SingleFile: true, Transport: tr})
+
+	a := New(&Config{
+		WriteKey:   "k",
+		Dir:        ".sf",
+		SingleFile: true,
+		Transport: TransportFunc(func(_ string, events []*Event) error {
+			return b.Flush()
+		}),
+	})
+
+	a.Track("x", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.sends != 0 {
+		t.Fatalf("expected the concurrent flush to be skipped, got %d sends", tr.sends)
+	}
+}
@@ -0,0 +1,37 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLastFlushDuration_skew(t *testing.T) {
+	home := testHome(t)
+	tr := &countTransport{}
+
+	a := New(&Config{WriteKey: "k", Dir: ".skew", Transport: tr, MinFlushInterval: time.Minute})
+
+	future := []byte(time.Now().Add(24 * time.Hour).Format(time.RFC3339Nano))
+	ioutil.WriteFile(filepath.Join(home, ".skew", "last_flush"), future, 0600)
+	ioutil.WriteFile(filepath.Join(home, ".skew", "last_flush_success"), future, 0600)
+
+	if d, err := a.LastFlushDuration(); err != nil || d != 0 {
+		t.Fatalf("expected 0 for a future last flush, got %s and %v", d, err)
+	}
+
+	if d, err := a.LastFlushSuccessDuration(); err != nil || d != 0 {
+		t.Fatalf("expected 0 for a future last success, got %s and %v", d, err)
+	}
+
+	a.Track("x", nil)
+
+	if err := a.ConditionalFlush(1000, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.sends != 1 {
+		t.Fatalf("expected a flush despite the skew, got %d sends", tr.sends)
+	}
+}
@@ -0,0 +1,68 @@
+package analytics
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+// appendRT appends an event to path on each request, simulating a
+// Track from another process while a flush is in flight.
+type appendRT struct {
+	path string
+	fail bool
+}
+
+func (r *appendRT) RoundTrip(req *http.Request) (*http.Response, error) {
+	f, _ := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	f.WriteString(`{"event":"during","properties":null,"timestamp":"0001-01-01T00:00:00Z"}` + "\n")
+	f.Close()
+
+	if r.fail {
+		return nil, os.ErrClosed
+	}
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestFlush_snapshot(t *testing.T) {
+	home := testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	rt := &appendRT{path: filepath.Join(home, ".snapshot", "events")}
+	c := &http.Client{Transport: rt}
+
+	a := New(&Config{WriteKey: "k", Dir: ".snapshot", Endpoint: s.URL, HTTPClient: c})
+	a.Track("before", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".snapshot", Endpoint: s.URL, HTTPClient: c})
+
+	if events, _ := b.Events(); len(events) != 1 || events[0].Event != "during" {
+		t.Fatalf("expected the event tracked during the flush kept, got %v", events)
+	}
+
+	rt.fail = true
+	b.Track("x", nil)
+
+	if err := b.Flush(); err == nil {
+		t.Fatal("expected a flush error")
+	}
+
+	events, _ := b.Events()
+
+	if len(events) != 3 || events[0].Event != "during" || events[1].Event != "x" || events[2].Event != "during" {
+		t.Fatalf("expected the snapshot restored ahead of new events, got %v", events)
+	}
+
+	if _, err := os.Stat(rt.path + ".flushing"); !os.IsNotExist(err) {
+		t.Fatalf("expected the snapshot removed, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package analytics
+
+import "testing"
+
+func TestSnapshot(t *testing.T) {
+	testHome(t)
+
+	a := New(&Config{WriteKey: "k", Dir: ".snap", Store: NewMemStore()})
+
+	a.Track("x", map[string]interface{}{
+		"plan": "pro",
+		"user": map[string]interface{}{"role": "admin"},
+		"tags": []interface{}{"a"},
+	})
+
+	events, err := a.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := events[0]
+	e.Event = "changed"
+	e.Properties["plan"] = "free"
+	e.Properties["user"].(map[string]interface{})["role"] = "guest"
+	e.Properties["tags"].([]interface{})[0] = "b"
+
+	events, _ = a.Snapshot()
+	e = events[0]
+
+	if e.Event != "x" || e.Properties["plan"] != "pro" {
+		t.Fatalf("expected the buffer unchanged, got %+v", e)
+	}
+
+	if e.Properties["user"].(map[string]interface{})["role"] != "admin" {
+		t.Fatalf("expected nested properties unchanged, got %v", e.Properties["user"])
+	}
+
+	if e.Properties["tags"].([]interface{})[0] != "a" {
+		t.Fatalf("expected nested slices unchanged, got %v", e.Properties["tags"])
+	}
+}
+
+func TestPendingByName(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".snap"})
+
+	a.Track("build", nil)
+	a.Track("deploy", nil)
+	a.Track("build", nil)
+	a.TrackPriority("deploy", nil, PriorityHigh)
+	a.Group("acme", nil)
+	a.RotateID()
+
+	pending, err := a.PendingByName()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pending) != 2 || pending["build"] != 2 || pending["deploy"] != 2 {
+		t.Fatalf("expected only track events by name, got %v", pending)
+	}
+}
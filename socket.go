@@ -0,0 +1,37 @@
+package analytics
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+)
+
+// writeSocket writes event `e` as a Line to the collector listening
+// on SocketPath, connecting when necessary.
+func (a *Analytics) writeSocket(e *Event) error {
+	if a.socket == nil {
+		conn, err := net.DialTimeout("unix", a.SocketPath, time.Second)
+		if err != nil {
+			return err
+		}
+
+		a.socket = conn
+	}
+
+	if err := json.NewEncoder(a.socket).Encode(a.line(e)); err != nil {
+		a.closeSocket()
+		return err
+	}
+
+	return nil
+}
+
+// closeSocket closes the collector connection, if any.
+func (a *Analytics) closeSocket() {
+	if a.socket == nil {
+		return
+	}
+
+	a.socket.Close()
+	a.socket = nil
+}
@@ -0,0 +1,66 @@
+package analytics
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestSocketPath(t *testing.T) {
+	testHome(t)
+	path := filepath.Join(t.TempDir(), "collector.sock")
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer l.Close()
+
+	lines := make(chan map[string]interface{}, 10)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		s := bufio.NewScanner(conn)
+		for s.Scan() {
+			var m map[string]interface{}
+			json.Unmarshal(s.Bytes(), &m)
+			lines <- m
+		}
+	}()
+
+	a := New(&Config{WriteKey: "k", Dir: ".socket", SocketPath: path})
+	a.Track("a", nil)
+	a.Track("b", nil)
+
+	for _, name := range []string{"a", "b"} {
+		if m := <-lines; m["event"] != name {
+			t.Fatalf("expected event %q on the socket, got %v", name, m)
+		}
+	}
+
+	if n, _ := a.Size(); n != 0 {
+		t.Fatalf("expected nothing buffered to disk, got %d", n)
+	}
+}
+
+func TestSocketPath_missing(t *testing.T) {
+	testHome(t)
+	path := filepath.Join(t.TempDir(), "missing.sock")
+
+	a := New(&Config{WriteKey: "k", Dir: ".socket", SocketPath: path})
+
+	if err := a.Track("a", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := a.Size(); n != 1 {
+		t.Fatalf("expected the event buffered to disk, got %d", n)
+	}
+}
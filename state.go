@@ -0,0 +1,234 @@
+package analytics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gofrs/flock"
+	"github.com/pkg/errors"
+)
+
+// state is the format of ~/<dir>/analytics.json when SingleFile is set,
+// holding the contents of the files it replaces, nil when missing.
+type state struct {
+	ID               *string  `json:"id,omitempty"`
+	Disable          *string  `json:"disable,omitempty"`
+	LastFlush        *string  `json:"last_flush,omitempty"`
+	LastFlushSuccess *string  `json:"last_flush_success,omitempty"`
+	Events           []*Event `json:"events,omitempty"`
+}
+
+// section returns the section of the state replacing file `name`.
+func (s *state) section(name string) **string {
+	switch name {
+	case "id":
+		return &s.ID
+	case "disable":
+		return &s.Disable
+	case "last_flush":
+		return &s.LastFlush
+	case "last_flush_success":
+		return &s.LastFlushSuccess
+	default:
+		panic("analytics: no state section for " + name)
+	}
+}
+
+// statePath returns the path of ~/<dir>/analytics.json.
+func (a *Analytics) statePath() string {
+	return filepath.Join(a.root, "analytics.json")
+}
+
+// readState reads the state, which is empty when the file doesn't exist.
+func (a *Analytics) readState() (*state, error) {
+	var s state
+
+	b, err := ioutil.ReadFile(a.statePath())
+	if os.IsNotExist(err) {
+		return &s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, errors.Wrap(err, "decoding state")
+	}
+
+	return &s, nil
+}
+
+// updateState applies `fn` to the state while holding ~/<dir>/analytics.json.lock,
+// writing it to a temporary file which replaces the state file, so that it's
+// never partially written.
+func (a *Analytics) updateState(fn func(*state)) error {
+	path := a.statePath()
+	l := flock.New(path + ".lock")
+
+	if err := l.Lock(); err != nil {
+		return errors.Wrap(err, "locking state")
+	}
+
+	defer l.Unlock()
+
+	s, err := a.readState()
+	if err != nil {
+		return err
+	}
+
+	fn(s)
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err, "encoding state")
+	}
+
+	tmp := path + ".tmp"
+
+	if err := ioutil.WriteFile(tmp, b, a.FilePerm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// readFile returns the contents of ~/<dir>/<name>, or of its
+// section of the state when SingleFile is set.
+func (a *Analytics) readFile(name string) ([]byte, error) {
+	if !a.SingleFile {
+		return ioutil.ReadFile(filepath.Join(a.root, name))
+	}
+
+	s, err := a.readState()
+	if err != nil {
+		return nil, err
+	}
+
+	v := *s.section(name)
+	if v == nil {
+		return nil, os.ErrNotExist
+	}
+
+	return []byte(*v), nil
+}
+
+// writeFile writes `b` to ~/<dir>/<name>, or to its section
+// of the state when SingleFile is set.
+func (a *Analytics) writeFile(name string, b []byte) error {
+	if !a.SingleFile {
+		return ioutil.WriteFile(filepath.Join(a.root, name), b, a.FilePerm)
+	}
+
+	v := string(b)
+
+	return a.updateState(func(s *state) {
+		*s.section(name) = &v
+	})
+}
+
+// removeFile removes ~/<dir>/<name>, or its section of the state
+// when SingleFile is set. Like os.Remove an error satisfying
+// os.IsNotExist is returned when it doesn't exist.
+func (a *Analytics) removeFile(name string) error {
+	if !a.SingleFile {
+		return os.Remove(filepath.Join(a.root, name))
+	}
+
+	var found bool
+
+	err := a.updateState(func(s *state) {
+		p := s.section(name)
+		found = *p != nil
+		*p = nil
+	})
+
+	if err == nil && !found {
+		return os.ErrNotExist
+	}
+
+	return err
+}
+
+// stateStore is the Store of the events in the state when SingleFile is set.
+type stateStore struct {
+	a *Analytics
+}
+
+// Append implementation.
+func (s *stateStore) Append(e *Event) error {
+	return s.a.updateState(func(v *state) {
+		v.Events = append(v.Events, e)
+	})
+}
+
+// ReadAll implementation.
+func (s *stateStore) ReadAll() ([]*Event, error) {
+	v, err := s.a.readState()
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Events, nil
+}
+
+// Reset implementation.
+func (s *stateStore) Reset() error {
+	return s.a.updateState(func(v *state) {
+		v.Events = nil
+	})
+}
+
+// Size implementation.
+func (s *stateStore) Size() (int, error) {
+	v, err := s.a.readState()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(v.Events), nil
+}
+
+// replace replaces the events of the state with `events` in a single write.
+func (s *stateStore) replace(events []*Event) error {
+	return s.a.updateState(func(v *state) {
+		v.Events = events
+	})
+}
+
+// settle replaces the `flushed` events of the state with `events`, matched
+// by message id, keeping any appended since they were read. This is a single
+// read-modify-write so that events tracked by other processes during the
+// flush aren't lost.
+func (s *stateStore) settle(flushed, events []*Event) error {
+	ids := make(map[string]bool, len(flushed))
+
+	for _, e := range flushed {
+		ids[e.MessageID] = true
+	}
+
+	return s.a.updateState(func(v *state) {
+		kept := append([]*Event{}, events...)
+
+		for _, e := range v.Events {
+			if !ids[e.MessageID] {
+				kept = append(kept, e)
+			}
+		}
+
+		v.Events = kept
+	})
+}
+
+// clearStore removes the `flushed` events from the Store, which is reset
+// unless it's the state, shared with other processes.
+func (a *Analytics) clearStore(flushed []*Event) error {
+	if s, ok := a.store.(*stateStore); ok {
+		return s.settle(flushed, nil)
+	}
+
+	return a.store.Reset()
+}
@@ -0,0 +1,25 @@
+package analytics
+
+// Status is the breakdown of why tracking is or isn't enabled.
+type Status struct {
+	EnabledEffective bool // EnabledEffective is true when events are tracked
+	DisabledByFile   bool // DisabledByFile is true when ~/<dir>/disable exists
+	DisabledByEnv    bool // DisabledByEnv is true when DO_NOT_TRACK is set
+	ConsentRequired  bool // ConsentRequired is true when tracking is opt-in
+	ConsentGranted   bool // ConsentGranted is true when the user opted in with GrantConsent()
+}
+
+// Status returns why tracking is or isn't enabled, such as for a status
+// command telling users how to change it. EffectiveConfig() has the
+// remaining settings.
+func (a *Analytics) Status() Status {
+	c := a.EffectiveConfig()
+
+	return Status{
+		EnabledEffective: c.Enabled,
+		DisabledByFile:   c.DisabledByFile,
+		DisabledByEnv:    c.DisabledByEnv,
+		ConsentRequired:  c.ConsentRequired,
+		ConsentGranted:   c.ConsentGranted,
+	}
+}
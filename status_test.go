@@ -0,0 +1,82 @@
+package analytics
+
+import "testing"
+
+func TestStatus(t *testing.T) {
+	testHome(t)
+	t.Setenv("DO_NOT_TRACK", "")
+
+	a := New(&Config{WriteKey: "k", Dir: ".st"})
+
+	if s := a.Status(); s != (Status{EnabledEffective: true}) {
+		t.Fatalf("expected enabled, got %+v", s)
+	}
+}
+
+func TestStatus_file(t *testing.T) {
+	testHome(t)
+	t.Setenv("DO_NOT_TRACK", "")
+
+	a := New(&Config{WriteKey: "k", Dir: ".st"})
+	a.Disable()
+
+	if s := a.Status(); s != (Status{DisabledByFile: true}) {
+		t.Fatalf("expected disabled by file, got %+v", s)
+	}
+}
+
+func TestStatus_env(t *testing.T) {
+	testHome(t)
+	t.Setenv("DO_NOT_TRACK", "1")
+
+	a := New(&Config{WriteKey: "k", Dir: ".st"})
+
+	if s := a.Status(); s != (Status{DisabledByEnv: true}) {
+		t.Fatalf("expected disabled by env, got %+v", s)
+	}
+}
+
+func TestStatus_consent(t *testing.T) {
+	testHome(t)
+	t.Setenv("DO_NOT_TRACK", "")
+
+	a := New(&Config{WriteKey: "k", Dir: ".st", ConsentRequired: true})
+
+	if s := a.Status(); s != (Status{ConsentRequired: true}) {
+		t.Fatalf("expected consent required, got %+v", s)
+	}
+
+	a.GrantConsent()
+
+	if s := a.Status(); s != (Status{EnabledEffective: true, ConsentRequired: true, ConsentGranted: true}) {
+		t.Fatalf("expected consent granted, got %+v", s)
+	}
+}
+
+func TestStatus_combined(t *testing.T) {
+	testHome(t)
+	t.Setenv("DO_NOT_TRACK", "")
+
+	a := New(&Config{WriteKey: "k", Dir: ".st", ConsentRequired: true})
+	a.GrantConsent()
+	a.Disable()
+	t.Setenv("DO_NOT_TRACK", "1")
+
+	want := Status{
+		DisabledByFile:  true,
+		DisabledByEnv:   true,
+		ConsentRequired: true,
+		ConsentGranted:  true,
+	}
+
+	if s := a.Status(); s != want {
+		t.Fatalf("expected %+v, got %+v", want, s)
+	}
+
+	a.Enable()
+	want.DisabledByFile = false
+
+	if s := a.Status(); s != want {
+		t.Fatalf("expected %+v, got %+v", want, s)
+	}
+}
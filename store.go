@@ -0,0 +1,53 @@
+package analytics
+
+import "sync"
+
+// Store buffers events in place of ~/<dir>/events.
+type Store interface {
+	Append(*Event) error
+	ReadAll() ([]*Event, error)
+	Reset() error
+	Size() (int, error)
+}
+
+// MemStore is an in-memory Store, useful for asserting on
+// tracked events in tests.
+type MemStore struct {
+	mu     sync.Mutex
+	events []*Event
+}
+
+// NewMemStore returns a new in-memory store.
+func NewMemStore() *MemStore {
+	return &MemStore{}
+}
+
+// Append implementation.
+func (s *MemStore) Append(e *Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+// ReadAll implementation.
+func (s *MemStore) ReadAll() ([]*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Event(nil), s.events...), nil
+}
+
+// Reset implementation.
+func (s *MemStore) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = nil
+	return nil
+}
+
+// Size implementation.
+func (s *MemStore) Size() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events), nil
+}
@@ -0,0 +1,40 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestMemStore(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	st := NewMemStore()
+	a := New(&Config{WriteKey: "k", Dir: ".store", Endpoint: s.URL, Store: st})
+	a.Track("x", nil)
+	a.TrackPriority("y", nil, PriorityHigh)
+
+	if n, _ := a.Size(); n != 2 {
+		t.Fatalf("expected 2 events, got %d", n)
+	}
+
+	events, _ := st.ReadAll()
+
+	if len(events) != 2 || events[0].Event != "x" {
+		t.Fatalf("expected the events in the store, got %v", events)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := st.Size(); n != 0 {
+		t.Fatalf("expected the store emptied, got %d", n)
+	}
+
+	if n := len(s.Messages()); n != 2 {
+		t.Fatalf("expected 2 messages, got %d", n)
+	}
+}
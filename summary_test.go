@@ -0,0 +1,54 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummaryEvent(t *testing.T) {
+	testHome(t)
+	tr := &recordTransport{}
+	start := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	a := New(&Config{WriteKey: "k", Dir: ".summary", Transport: tr, SummaryEvent: "Session"})
+	a.TrackAt("Build", nil, start)
+	a.TrackAt("Build", nil, start.Add(10*time.Second))
+	a.TrackAt("Deploy", nil, start.Add(30*time.Second))
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.events) != 4 {
+		t.Fatalf("expected the events and the summary, got %d", len(tr.events))
+	}
+
+	s := tr.events[3]
+
+	if s.Event != "Session" || s.Properties["total"] != 3 || s.Properties["duration"] != 30.0 {
+		t.Fatalf("expected the summary totals, got %+v", s)
+	}
+
+	counts := s.Properties["counts"].(map[string]int)
+
+	if counts["Build"] != 2 || counts["Deploy"] != 1 {
+		t.Fatalf("expected counts per event name, got %v", counts)
+	}
+}
+
+func TestSummaryEvent_only(t *testing.T) {
+	testHome(t)
+	tr := &recordTransport{}
+
+	a := New(&Config{WriteKey: "k", Dir: ".summary", Transport: tr, SummaryEvent: "Session", SummaryOnly: true})
+	a.Track("Build", nil)
+	a.Track("Deploy", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.events) != 1 || tr.events[0].Event != "Session" {
+		t.Fatalf("expected only the summary, got %v", tr.events)
+	}
+}
@@ -0,0 +1,36 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMinFlushInterval(t *testing.T) {
+	home := testHome(t)
+	tr := &countTransport{}
+
+	a := New(&Config{WriteKey: "k", Dir: ".throttle", Transport: tr, MinFlushInterval: time.Hour})
+	os.Remove(filepath.Join(home, ".throttle", "last_flush"))
+	a.Track("a", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".throttle", Transport: tr, MinFlushInterval: time.Hour})
+	b.Track("b", nil)
+
+	if err := b.Flush(); err != ErrFlushThrottled {
+		t.Fatalf("expected ErrFlushThrottled, got %v", err)
+	}
+
+	if tr.sends != 1 {
+		t.Fatalf("expected 1 send, got %d", tr.sends)
+	}
+
+	if n, _ := New(&Config{WriteKey: "k", Dir: ".throttle"}).Size(); n != 1 {
+		t.Fatalf("expected the throttled event kept, got %d", n)
+	}
+}
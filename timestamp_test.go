@@ -0,0 +1,38 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestTimestamps(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	c := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 123456789, time.UTC)}
+	a := New(&Config{WriteKey: "k", Dir: ".ts", Endpoint: s.URL, Clock: c})
+
+	first, _ := a.TrackEvent("a", nil)
+	c.t = c.t.Add(time.Hour)
+	second, _ := a.TrackEvent("b", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := s.Messages()
+
+	for i, e := range []*Event{first, second} {
+		ts, err := time.Parse(time.RFC3339Nano, m[i]["timestamp"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !ts.Equal(e.Timestamp) {
+			t.Fatalf("expected the tracked time %s, got %s", e.Timestamp, ts)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTouch(t *testing.T) {
+	home := testHome(t)
+	c := &fakeClock{t: time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)}
+	a := New(&Config{WriteKey: "k", Dir: ".touch", Clock: c})
+
+	if err := a.Touch(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(home, ".touch", "last_flush")
+	os.Chtimes(path, time.Now(), time.Now())
+
+	if got, err := a.LastFlush(); err != nil || !got.Equal(c.t) {
+		t.Fatalf("expected the written timestamp over the mtime, got %s and %v", got, err)
+	}
+}
+
+func TestTouch_legacy(t *testing.T) {
+	home := testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".touch"})
+
+	path := filepath.Join(home, ".touch", "last_flush")
+	ioutil.WriteFile(path, []byte(":)"), 0600)
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(path, old, old)
+
+	if got, _ := a.LastFlush(); !got.Equal(old) {
+		t.Fatalf("expected the mtime for a legacy file, got %s", got)
+	}
+}
@@ -0,0 +1,34 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tj/go-cli-analytics/analyticstest"
+)
+
+func TestTrackAt(t *testing.T) {
+	testHome(t)
+	s := analyticstest.NewServer()
+	defer s.Close()
+
+	a := New(&Config{WriteKey: "k", Dir: ".at", Endpoint: s.URL})
+	ts := time.Now().Add(-time.Hour).Round(time.Millisecond)
+
+	a.TrackAt("old", nil, ts)
+	a.TrackAt("now", nil, time.Time{})
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := s.Messages()
+
+	if len(m) != 2 || m[0]["timestamp"] != ts.UTC().Format(time.RFC3339Nano) {
+		t.Fatalf("expected the explicit timestamp, got %v", m)
+	}
+
+	if got, _ := time.Parse(time.RFC3339Nano, m[1]["timestamp"].(string)); time.Since(got) > time.Minute {
+		t.Fatalf("expected a zero time to mean now, got %v", got)
+	}
+}
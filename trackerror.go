@@ -0,0 +1,57 @@
+package analytics
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// stackTracer is implemented by errors carrying a stack trace,
+// such as those created by github.com/pkg/errors.
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// TrackError tracks event `name` for `err` with optional `props`. The
+// event has an "error" property with the message, an "error_type" property
+// with the type of the root cause, and a "stack" property when the error
+// carries a stack trace. These take precedence over `props`. This method
+// is a no-op when `err` is nil.
+func (a *Analytics) TrackError(name string, err error, props map[string]interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	v := make(map[string]interface{}, len(props)+3)
+
+	for k, val := range props {
+		v[k] = val
+	}
+
+	v["error"] = err.Error()
+	v["error_type"] = fmt.Sprintf("%T", errors.Cause(err))
+
+	if st := stackTrace(err); st != nil {
+		v["stack"] = fmt.Sprintf("%+v", st)
+	}
+
+	return a.Track(name, v)
+}
+
+// stackTrace returns the innermost stack trace of `err`, or nil.
+func stackTrace(err error) (st errors.StackTrace) {
+	for err != nil {
+		if s, ok := err.(stackTracer); ok {
+			st = s.StackTrace()
+		}
+
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+
+		err = u.Unwrap()
+	}
+
+	return st
+}
@@ -0,0 +1,38 @@
+package analytics
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestTrackError(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".trackerror"})
+
+	if err := a.TrackError("Error", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	a.TrackError("Error", stderrors.New("plain"), map[string]interface{}{"cmd": "build", "error": "x"})
+	a.TrackError("Error", errors.Wrap(errors.New("deep"), "outer"), nil)
+
+	events, _ := a.Events()
+
+	if len(events) != 2 {
+		t.Fatalf("expected a nil error to be skipped, got %d events", len(events))
+	}
+
+	p := events[0].Properties
+
+	if p["error"] != "plain" || p["cmd"] != "build" || p["error_type"] != "*errors.errorString" || p["stack"] != nil {
+		t.Fatalf("expected the plain error schema, got %v", p)
+	}
+
+	p = events[1].Properties
+
+	if p["error"] != "outer: deep" || p["stack"] == nil || p["error_type"] != "*errors.fundamental" {
+		t.Fatalf("expected the wrapped error with a stack, got %v", p)
+	}
+}
@@ -0,0 +1,41 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackEvent(t *testing.T) {
+	testHome(t)
+	c := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	a := New(&Config{WriteKey: "k", Dir: ".trackevent", Clock: c})
+	a.SetGlobalProperties(map[string]interface{}{"version": "1.0.0"})
+
+	e, err := a.TrackEvent("Deploy", map[string]interface{}{"region": "us"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Event != "Deploy" || !e.Timestamp.Equal(c.t) {
+		t.Fatalf("expected the event at the clock time, got %+v", e)
+	}
+
+	if e.Properties["version"] != "1.0.0" || e.Properties["region"] != "us" {
+		t.Fatalf("expected the merged properties, got %v", e.Properties)
+	}
+
+	events, _ := a.Events()
+
+	if len(events) != 1 || events[0].MessageID != e.MessageID {
+		t.Fatalf("expected the returned event buffered, got %v", events)
+	}
+}
+
+func TestTrackEvent_dropped(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".trackevent"}, WithSampleRate(0))
+
+	if e, err := a.TrackEvent("x", nil); e != nil || err != nil {
+		t.Fatalf("expected a nil event for a dropped event, got %v and %v", e, err)
+	}
+}
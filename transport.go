@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// TransportFunc adapts a function to a Transport, see FlushTo().
+type TransportFunc func(userID string, events []*Event) error
+
+// Send implementation.
+func (fn TransportFunc) Send(userID string, events []*Event) error {
+	return fn(userID, events)
+}
+
+// Close implementation.
+func (fn TransportFunc) Close() error {
+	return nil
+}
+
+// FileTransport is a Transport appending the flushed events to a file as
+// Lines of JSON, never touching the network, such as for asserting on
+// analytics in tests or CI. The id the events are sent with is the Line's
+// "user_id".
+type FileTransport struct {
+	path string
+}
+
+// NewFileTransport returns a transport appending to the file at `path`.
+func NewFileTransport(path string) *FileTransport {
+	return &FileTransport{path: path}
+}
+
+// Send implementation.
+func (t *FileTransport) Send(userID string, events []*Event) error {
+	f, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrap(err, "opening")
+	}
+
+	enc := json.NewEncoder(f)
+
+	for _, e := range events {
+		l := &Line{
+			Type:       e.Type,
+			Event:      e.Event,
+			UserID:     userID,
+			PreviousID: e.PreviousID,
+			Properties: e.Properties,
+			Traits:     e.Traits,
+			GroupID:    e.GroupID,
+			Timestamp:  e.Timestamp,
+		}
+
+		if l.Type == "" {
+			l.Type = TypeTrack
+		}
+
+		if l.Type == TypeAlias {
+			l.UserID = e.UserID
+		}
+
+		if err := enc.Encode(l); err != nil {
+			f.Close()
+			return errors.Wrap(err, "writing")
+		}
+	}
+
+	return f.Close()
+}
+
+// Close implementation.
+func (t *FileTransport) Close() error {
+	return nil
+}
@@ -0,0 +1,51 @@
+package analytics
+
+import "testing"
+
+// spyTransport records its sends and closes.
+type spyTransport struct {
+	id     string
+	events []*Event
+	closed int
+}
+
+func (s *spyTransport) Send(id string, events []*Event) error {
+	s.id = id
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *spyTransport) Close() error {
+	s.closed++
+	return nil
+}
+
+func TestTransport(t *testing.T) {
+	testHome(t)
+	tr := &spyTransport{}
+
+	a := New(&Config{WriteKey: "k", Dir: ".transport", Transport: tr})
+	a.Track("a", nil)
+	a.Track("b", nil)
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.id != a.anonymousID || len(tr.events) != 2 || tr.events[1].Event != "b" {
+		t.Fatalf("expected both events for the anonymous id, got %+v", tr)
+	}
+
+	if tr.closed != 1 {
+		t.Fatalf("expected 1 close, got %d", tr.closed)
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".transport", Transport: tr})
+	b.SetUserID("u")
+	b.Track("c", nil)
+	b.Flush()
+
+	if tr.id != "u" {
+		t.Fatalf("expected the user id, got %q", tr.id)
+	}
+}
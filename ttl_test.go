@@ -0,0 +1,59 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxRetryAge(t *testing.T) {
+	testHome(t)
+	tr := &recordTransport{}
+	c := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var dropped []string
+
+	a := New(&Config{
+		WriteKey:    "k",
+		Dir:         ".ttl",
+		Transport:   tr,
+		Clock:       c,
+		MaxRetryAge: 24 * time.Hour,
+		OnDrop:      func(e *Event, err error) { dropped = append(dropped, e.Event) },
+	})
+
+	a.Track("stale", nil)
+	c.t = c.t.Add(30 * 24 * time.Hour)
+	a.Track("fresh", nil)
+
+	if events, _ := a.Events(); len(events) != 1 || events[0].Event != "fresh" {
+		t.Fatalf("expected the stale event hidden, got %v", events)
+	}
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.events) != 1 || tr.events[0].Event != "fresh" {
+		t.Fatalf("expected only the fresh event delivered, got %v", tr.events)
+	}
+
+	if len(dropped) != 1 || dropped[0] != "stale" {
+		t.Fatalf("expected the stale event dropped, got %v", dropped)
+	}
+}
+
+func TestMaxRetryAge_backfilled(t *testing.T) {
+	testHome(t)
+	tr := &recordTransport{}
+	now := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	a := New(&Config{WriteKey: "k", Dir: ".ttl", Transport: tr, Clock: &fakeClock{t: now}, MaxRetryAge: 24 * time.Hour})
+	a.TrackAt("imported", nil, now.Add(-30*24*time.Hour))
+
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.events) != 1 || tr.events[0].Event != "imported" {
+		t.Fatalf("expected the backfilled event delivered, got %v", tr.events)
+	}
+}
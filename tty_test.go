@@ -0,0 +1,28 @@
+package analytics
+
+import "testing"
+
+func TestDetectTTY(t *testing.T) {
+	testHome(t)
+	defer func(fn func() bool) { isTerminal = fn }(isTerminal)
+
+	a := New(&Config{WriteKey: "k", Dir: ".tty", DetectTTY: true})
+
+	for _, tty := range []bool{true, false} {
+		isTerminal = func() bool { return tty }
+		a.Track("x", nil)
+	}
+
+	events, _ := a.Events()
+
+	if len(events) != 2 || events[0].Properties["interactive"] != true || events[1].Properties["interactive"] != false {
+		t.Fatalf("expected the interactive property, got %v", events)
+	}
+
+	b := New(&Config{WriteKey: "k", Dir: ".tty2"})
+	b.Track("x", nil)
+
+	if events, _ := b.Events(); events[0].Properties["interactive"] != nil {
+		t.Fatalf("expected no interactive property, got %v", events[0].Properties)
+	}
+}
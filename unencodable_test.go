@@ -0,0 +1,22 @@
+package analytics
+
+import "testing"
+
+func TestTrack_unencodable(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".unencodable"})
+
+	if err := a.Track("A", map[string]interface{}{"f": func() {}, "c": make(chan int), "ok": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := a.Events()
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+
+	if p := events[0].Properties; len(p) != 1 || p["ok"] != 1.0 {
+		t.Fatalf("expected only the encodable property, got %v", p)
+	}
+}
@@ -0,0 +1,31 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUserID(t *testing.T) {
+	home := testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".uid"})
+
+	b, _ := ioutil.ReadFile(filepath.Join(home, ".uid", "id"))
+
+	if a.UserID() == "" || !strings.Contains(string(b), a.UserID()) {
+		t.Fatalf("expected the stored id, got %q", a.UserID())
+	}
+
+	a.SetUserID("acct")
+
+	if id := a.UserID(); id != "acct" {
+		t.Fatalf("expected the account id, got %q", id)
+	}
+
+	a.Disable()
+
+	if id := New(&Config{WriteKey: "k", Dir: ".uid"}).UserID(); id != "" {
+		t.Fatalf("expected no id when disabled, got %q", id)
+	}
+}
@@ -0,0 +1,44 @@
+package analytics
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	testHome(t)
+
+	if err := New(&Config{Dir: ".validate"}).Validate(); err == nil {
+		t.Fatal("expected a missing write key error")
+	}
+
+	if err := New(&Config{WriteKey: "k"}).Validate(); err == nil {
+		t.Fatal("expected a missing dir error")
+	}
+
+	if err := New(&Config{WriteKey: "k", Dir: "~bob/x"}).Validate(); err == nil {
+		t.Fatal("expected an unsupported ~user error")
+	}
+
+	if err := New(&Config{Dir: ".validate", DryRun: true}).Validate(); err != nil {
+		t.Fatalf("expected no write key needed for DryRun, got %v", err)
+	}
+
+	if err := New(&Config{Dir: ".validate", Transport: &countTransport{}}).Validate(); err != nil {
+		t.Fatalf("expected no write key needed with a Transport, got %v", err)
+	}
+
+	if err := New(&Config{WriteKey: "k", Dir: t.TempDir()}).Validate(); err != nil {
+		t.Fatalf("expected an absolute dir to be valid, got %v", err)
+	}
+}
+
+func TestValidate_track(t *testing.T) {
+	testHome(t)
+	a := New(&Config{Dir: ".validate"})
+
+	if err := a.Track("x", nil); err == nil {
+		t.Fatal("expected Track to return the setup error")
+	}
+
+	if err := a.Flush(); err == nil {
+		t.Fatal("expected Flush to return the setup error")
+	}
+}
@@ -0,0 +1,36 @@
+package analytics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestValidateTrack(t *testing.T) {
+	testHome(t)
+	invalid := errors.New("event names must be Title Case")
+
+	a := New(&Config{WriteKey: "k", Dir: ".validatetrack", ValidateEventName: func(name string) error {
+		if strings.ToUpper(name[:1]) != name[:1] {
+			return invalid
+		}
+		return nil
+	}})
+
+	if err := a.ValidateTrack("deploy", nil); errors.Cause(err) != invalid {
+		t.Fatalf("expected the naming error, got %v", err)
+	}
+
+	if err := a.ValidateTrack("", nil); err != ErrEmptyEventName {
+		t.Fatalf("expected ErrEmptyEventName, got %v", err)
+	}
+
+	if err := a.ValidateTrack("Deploy", map[string]interface{}{"region": "us"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := a.Size(); n != 0 {
+		t.Fatalf("expected nothing buffered, got %d", n)
+	}
+}
@@ -0,0 +1,34 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apex/log"
+)
+
+type logHandler struct {
+	messages []string
+}
+
+func (h *logHandler) HandleLog(e *log.Entry) error {
+	h.messages = append(h.messages, e.Message)
+	return nil
+}
+
+func TestWithLogger(t *testing.T) {
+	home := testHome(t)
+	os.MkdirAll(filepath.Join(home, ".wl", "events"), 0700)
+	h := &logHandler{}
+
+	New(&Config{WriteKey: "k", Dir: ".wl"}, WithLogger(&log.Logger{Handler: h}))
+
+	for _, m := range h.messages {
+		if m == "error opening events" {
+			return
+		}
+	}
+
+	t.Fatalf("expected the open error logged, got %v", h.messages)
+}
@@ -0,0 +1,29 @@
+package analytics
+
+import "testing"
+
+func TestSetWorkspace(t *testing.T) {
+	testHome(t)
+	a := New(&Config{WriteKey: "k", Dir: ".workspace"})
+
+	a.Track("none", nil)
+	a.SetWorkspace("ws-1")
+	a.Track("first", nil)
+	a.SetWorkspace("ws-2")
+	a.Track("second", nil)
+	a.SetWorkspace("")
+	a.Track("cleared", nil)
+
+	events, _ := a.Events()
+	expected := []interface{}{nil, "ws-1", "ws-2", nil}
+
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+
+	for i, e := range events {
+		if e.Properties["workspace_id"] != expected[i] {
+			t.Fatalf("expected %q tagged %v, got %v", e.Event, expected[i], e.Properties["workspace_id"])
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package analytics
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteKey(t *testing.T) {
+	home := testHome(t)
+	t.Setenv("SEGMENT_WRITE_KEY", "")
+	file := filepath.Join(home, "key")
+	ioutil.WriteFile(file, []byte("from-file\n"), 0600)
+
+	a := New(&Config{Dir: ".wk", WriteKeyFile: file})
+
+	if err := a.Validate(); err != nil || a.WriteKey != "from-file" {
+		t.Fatalf("expected the key file, got %q and %v", a.WriteKey, err)
+	}
+
+	t.Setenv("SEGMENT_WRITE_KEY", "from-env")
+
+	if a := New(&Config{Dir: ".wk", WriteKeyFile: file}); a.WriteKey != "from-env" {
+		t.Fatalf("expected the environment over the key file, got %q", a.WriteKey)
+	}
+
+	t.Setenv("MY_KEY", "from-my-env")
+
+	if a := New(&Config{Dir: ".wk", WriteKeyEnv: "MY_KEY"}); a.WriteKey != "from-my-env" {
+		t.Fatalf("expected WriteKeyEnv, got %q", a.WriteKey)
+	}
+
+	if a := New(&Config{WriteKey: "explicit", Dir: ".wk", WriteKeyFile: file}); a.WriteKey != "explicit" {
+		t.Fatalf("expected the explicit key, got %q", a.WriteKey)
+	}
+}
+
+func TestWriteKey_missing(t *testing.T) {
+	home := testHome(t)
+	t.Setenv("SEGMENT_WRITE_KEY", "")
+
+	if err := New(&Config{Dir: ".wk"}).Validate(); err == nil {
+		t.Fatal("expected a missing write key error")
+	}
+
+	if err := New(&Config{Dir: ".wk", WriteKeyFile: filepath.Join(home, "missing")}).Validate(); err == nil {
+		t.Fatal("expected a missing key file error")
+	}
+
+	if err := New(&Config{Dir: ".wk", DryRun: true}).Validate(); err != nil {
+		t.Fatalf("expected no write key needed for DryRun, got %v", err)
+	}
+}
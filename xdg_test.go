@@ -0,0 +1,38 @@
+package analytics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUseXDG(t *testing.T) {
+	testHome(t)
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+
+	a := New(&Config{WriteKey: "k", Dir: "app", UseXDG: true})
+	a.Track("x", nil)
+	a.Close()
+
+	for _, name := range []string{"id", "events", "last_flush"} {
+		if _, err := os.Stat(filepath.Join(state, "app", name)); err != nil {
+			t.Fatalf("expected %s under XDG_STATE_HOME, got %v", name, err)
+		}
+	}
+}
+
+func TestUseXDG_default(t *testing.T) {
+	home := testHome(t)
+	t.Setenv("XDG_STATE_HOME", "")
+
+	a := New(&Config{WriteKey: "k", Dir: "app", UseXDG: true})
+
+	if a.root != filepath.Join(home, ".local", "state", "app") {
+		t.Fatalf("expected ~/.local/state/app, got %s", a.root)
+	}
+
+	if _, err := os.Stat(filepath.Join(a.root, "id")); err != nil {
+		t.Fatal(err)
+	}
+}